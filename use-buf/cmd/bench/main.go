@@ -0,0 +1,276 @@
+// Command bench drives one RPC of GreetService or GreetingService under load
+// and reports throughput and latency percentiles:
+//
+//	bench -rpc greet -concurrency 50 -duration 30s
+//	bench -rpc hello -rate 2000 -payload 8,32,64 -output json > hello.json
+//	bench -rpc everyone -stream-messages 100
+//
+// Without -rate, each of the -concurrency workers sends its next request as
+// soon as the previous one completes (closed model). With -rate, requests
+// are started at that fixed rate regardless of how fast the server responds
+// (open model); latency is then measured from the time a request was due, so
+// that time spent waiting for a free worker is included.
+//
+// The RPC is run once per -payload size, the length of the names sent in
+// every request. Names are limited to 64 characters by the servers'
+// validation, so payloads are limited to 64 bytes for hello and 128 bytes,
+// split into first and last name, for the GreetService RPCs.
+//
+// Throughput and latency are those of successful RPCs; failed RPCs are
+// counted per status code and reported separately.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+	_ "github.com/hrfmmr/grpc-go-sandbox/lb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Default targets of the RPCs: GreetingService is served in plaintext by
+// use-buf/cmd/server, GreetService over TLS by greet_server.
+const (
+	defaultGreetingTarget = "localhost:8080"
+	defaultGreetTarget    = "localhost:50051"
+)
+
+func main() {
+	rpc := flag.String("rpc", rpcGreet, "RPC to drive: greet, hello, many (GreetManyTimes), long (LongGreet) or everyone (GreetEveryone)")
+	target := flag.String("target", "", "server address, also dns:///, static:/// or file:/// targets of several backends (default "+defaultGreetingTarget+" for hello, "+defaultGreetTarget+" otherwise)")
+	plaintext := flag.Bool("plaintext", false, "connect to GreetService without TLS (GreetingService is always plaintext)")
+	caFile := flag.String("ca", "../use-self-signed-tls/ssl/ca.crt", "CA certificate to verify the GreetService server with")
+	certFile := flag.String("cert", "", "client certificate file for a GreetService server requiring mutual TLS")
+	keyFile := flag.String("key", "", "client private key file for a GreetService server requiring mutual TLS")
+	tokenFile := flag.String("token-file", "", "file holding the bearer token to send with every RPC")
+	concurrency := flag.Int("concurrency", 10, "number of workers making RPCs concurrently")
+	connections := flag.Int("connections", 1, "number of connections the workers are spread over")
+	rate := flag.Float64("rate", 0, "requests per second to start (open model); 0 to let every worker send back to back (closed model)")
+	duration := flag.Duration("duration", 10*time.Second, "duration of each run")
+	warmup := flag.Duration("warmup", 0, "duration to send requests for before each run without recording them")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline of each RPC")
+	payloads := flag.String("payload", "16", "comma separated payload sizes in bytes; the RPC is run once per size")
+	streamMessages := flag.Int("stream-messages", 10, "messages per RPC for many, long and everyone")
+	streamInterval := flag.Duration("stream-interval", time.Millisecond, "interval between GreetManyTimes responses (at least 1ms)")
+	output := flag.String("output", "text", "report format: text or json")
+	flag.Parse()
+
+	sizes, err := parseSizes(*payloads)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, size := range sizes {
+		if size > maxPayload(*rpc) {
+			log.Fatalf("-payload %d exceeds the %d bytes of names %s requests accept", size, maxPayload(*rpc), *rpc)
+		}
+	}
+	if *concurrency < 1 || *connections < 1 {
+		log.Fatal("-concurrency and -connections must be at least 1")
+	}
+	if *streamInterval < time.Millisecond {
+		log.Fatal("-stream-interval must be at least 1ms")
+	}
+	write := writeText
+	switch *output {
+	case "text":
+	case "json":
+		write = writeJSON
+	default:
+		log.Fatalf("unknown output format %q", *output)
+	}
+	if *target == "" {
+		*target = defaultGreetTarget
+		if *rpc == rpcHello {
+			*target = defaultGreetingTarget
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if *rpc != rpcHello && !*plaintext {
+		creds, err = newClientCreds(*caFile, *certFile, *keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if *tokenFile != "" {
+		token, err := auth.BearerTokenFromFile(*tokenFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(token))
+	}
+	conns := make([]*grpc.ClientConn, *connections)
+	for i := range conns {
+		cc, err := grpc.Dial(*target, opts...)
+		if err != nil {
+			log.Fatalf("could not connect:%v", err)
+		}
+		defer cc.Close()
+		conns[i] = cc
+	}
+
+	var reports []report
+	for _, size := range sizes {
+		callers := make([]caller, *concurrency)
+		for i := range callers {
+			callers[i], err = newCaller(*rpc, conns[i%len(conns)], callerConfig{
+				payload:  size,
+				messages: *streamMessages,
+				interval: *streamInterval,
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		l := loadConfig{rate: *rate, timeout: *timeout}
+		if *warmup > 0 {
+			log.Printf("Warming up %s for %v\n", *rpc, *warmup)
+			l.duration = *warmup
+			runLoad(callers, l)
+		}
+		log.Printf("Running %s for %v, payload %dB\n", *rpc, *duration, size)
+		l.duration = *duration
+		s, elapsed := runLoad(callers, l)
+
+		r := report{
+			RPC:             *rpc,
+			Target:          *target,
+			Model:           "closed",
+			Concurrency:     *concurrency,
+			Connections:     *connections,
+			Rate:            *rate,
+			Duration:        duration.String(),
+			PayloadBytes:    size,
+			Requests:        s.requests,
+			Succeeded:       s.succeeded,
+			Errors:          s.errors,
+			Messages:        s.messages,
+			ElapsedSeconds:  elapsed.Seconds(),
+			Throughput:      float64(s.succeeded) / elapsed.Seconds(),
+			ErrorRate:       float64(s.requests-s.succeeded) / elapsed.Seconds(),
+			MessageRate:     float64(s.messages) / elapsed.Seconds(),
+			Latency:         newLatencyReport(s.latency),
+			LatencyOverflow: s.overflow,
+		}
+		if *rate > 0 {
+			r.Model = "open"
+		}
+		if *rpc != rpcGreet && *rpc != rpcHello {
+			r.StreamMessages = *streamMessages
+		}
+		reports = append(reports, r)
+	}
+	if err := write(os.Stdout, reports); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type loadConfig struct {
+	duration time.Duration
+	rate     float64
+	timeout  time.Duration
+}
+
+// runLoad calls every caller from its own worker for l.duration and returns
+// the merged stats and the time it took for all workers to finish.
+func runLoad(callers []caller, l loadConfig) (*stats, time.Duration) {
+	start := time.Now()
+	end := start.Add(l.duration)
+
+	// In the open model, the due times of requests are queued for the
+	// workers at the configured rate.
+	var due chan time.Time
+	if l.rate > 0 {
+		due = make(chan time.Time, len(callers))
+		go func() {
+			defer close(due)
+			interval := time.Duration(float64(time.Second) / l.rate)
+			for t := start; t.Before(end); t = t.Add(interval) {
+				time.Sleep(time.Until(t))
+				due <- t
+			}
+		}()
+	}
+
+	all := make([]*stats, len(callers))
+	var wg sync.WaitGroup
+	for i, call := range callers {
+		wg.Add(1)
+		s := newStats()
+		all[i] = s
+		go func(call caller) {
+			defer wg.Done()
+			for {
+				var t time.Time
+				if due != nil {
+					var ok bool
+					if t, ok = <-due; !ok {
+						return
+					}
+				} else if t = time.Now(); !t.Before(end) {
+					return
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+				n, err := call(ctx)
+				cancel()
+				s.record(time.Since(t), n, err)
+			}
+		}(call)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := newStats()
+	for _, s := range all {
+		total.merge(s)
+	}
+	return total, elapsed
+}
+
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid payload size %q", v)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+func newClientCreds(caFile, certFile, keyFile string) (credentials.TransportCredentials, error) {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	cfg := &tls.Config{
+		RootCAs: pool,
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
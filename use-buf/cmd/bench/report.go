@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"google.golang.org/grpc/status"
+)
+
+// Latencies are recorded in microseconds from 1µs to 1 minute with 3
+// significant digits. Slower successes, possible with a long -timeout or an
+// open model falling behind, are recorded as 1 minute and counted apart.
+const (
+	minLatency = 1
+	maxLatency = int64(time.Minute / time.Microsecond)
+	sigFigs    = 3
+)
+
+// stats accumulates the results of one worker. Workers record into their
+// own stats, which are merged once they are done.
+type stats struct {
+	// latency is recorded for successful RPCs only.
+	latency *hdrhistogram.Histogram
+	// overflow counts the successes slower than maxLatency.
+	overflow  int64
+	requests  int64
+	succeeded int64
+	messages  int64
+	errors    map[string]int64
+}
+
+func newStats() *stats {
+	return &stats{
+		latency: hdrhistogram.New(minLatency, maxLatency, sigFigs),
+		errors:  make(map[string]int64),
+	}
+}
+
+func (s *stats) record(latency time.Duration, messages int, err error) {
+	s.requests++
+	s.messages += int64(messages)
+	if err != nil {
+		s.errors[status.Code(err).String()]++
+		return
+	}
+	s.succeeded++
+	if err := s.latency.RecordValue(int64(latency / time.Microsecond)); err != nil {
+		s.overflow++
+		s.latency.RecordValue(maxLatency)
+	}
+}
+
+func (s *stats) merge(o *stats) {
+	s.latency.Merge(o.latency)
+	s.overflow += o.overflow
+	s.requests += o.requests
+	s.succeeded += o.succeeded
+	s.messages += o.messages
+	for code, n := range o.errors {
+		s.errors[code] += n
+	}
+}
+
+// report is the result of one benchmark run. Its JSON encoding is stable so
+// that reports of different commits can be diffed.
+type report struct {
+	RPC            string           `json:"rpc"`
+	Target         string           `json:"target"`
+	Model          string           `json:"model"`
+	Concurrency    int              `json:"concurrency"`
+	Connections    int              `json:"connections"`
+	Rate           float64          `json:"rate,omitempty"`
+	Duration       string           `json:"duration"`
+	PayloadBytes   int              `json:"payload_bytes"`
+	StreamMessages int              `json:"stream_messages,omitempty"`
+	Requests       int64            `json:"requests"`
+	Succeeded      int64            `json:"succeeded"`
+	Errors         map[string]int64 `json:"errors,omitempty"`
+	Messages       int64            `json:"messages"`
+	ElapsedSeconds float64          `json:"elapsed_seconds"`
+	// Throughput is the rate of successful RPCs, ErrorRate that of failed
+	// ones.
+	Throughput  float64       `json:"throughput_rps"`
+	ErrorRate   float64       `json:"errors_per_second"`
+	MessageRate float64       `json:"messages_per_second"`
+	Latency     latencyReport `json:"latency_ms"`
+	// LatencyOverflow is the number of successes slower than the 1 minute
+	// the latencies go up to, which are counted in them as 1 minute.
+	LatencyOverflow int64 `json:"latency_overflow,omitempty"`
+}
+
+type latencyReport struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99.9"`
+	Max  float64 `json:"max"`
+}
+
+func newLatencyReport(h *hdrhistogram.Histogram) latencyReport {
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	return latencyReport{
+		Min:  ms(h.Min()),
+		Mean: h.Mean() / 1000,
+		P50:  ms(h.ValueAtQuantile(50)),
+		P90:  ms(h.ValueAtQuantile(90)),
+		P95:  ms(h.ValueAtQuantile(95)),
+		P99:  ms(h.ValueAtQuantile(99)),
+		P999: ms(h.ValueAtQuantile(99.9)),
+		Max:  ms(h.Max()),
+	}
+}
+
+func writeJSON(w io.Writer, reports []report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+func writeText(w io.Writer, reports []report) error {
+	for _, r := range reports {
+		model := r.Model
+		if r.Rate > 0 {
+			model = fmt.Sprintf("%s, %.0f/s", model, r.Rate)
+		}
+		fmt.Fprintf(w, "%s %s: %s, concurrency %d, %d connection(s), payload %dB\n",
+			r.RPC, r.Target, model, r.Concurrency, r.Connections, r.PayloadBytes)
+		fmt.Fprintf(w, "  requests   %d in %.2fs, %d succeeded, %.1f/s", r.Requests, r.ElapsedSeconds, r.Succeeded, r.Throughput)
+		if r.StreamMessages > 0 {
+			fmt.Fprintf(w, ", %d messages, %.1f/s", r.Messages, r.MessageRate)
+		}
+		fmt.Fprintln(w)
+		codes := make([]string, 0, len(r.Errors))
+		for code := range r.Errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		if len(codes) > 0 {
+			fmt.Fprintf(w, "  errors     %d, %.1f/s\n", r.Requests-r.Succeeded, r.ErrorRate)
+		}
+		for _, code := range codes {
+			fmt.Fprintf(w, "    %s: %d\n", code, r.Errors[code])
+		}
+		l := r.Latency
+		fmt.Fprintf(w, "  latency ms min %.3f  mean %.3f  p50 %.3f  p90 %.3f  p95 %.3f  p99 %.3f  p99.9 %.3f  max %.3f\n",
+			l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.P999, l.Max)
+		if r.LatencyOverflow > 0 {
+			max := time.Duration(maxLatency) * time.Microsecond
+			fmt.Fprintf(w, "  overflow   %d slower than %v, counted as %v\n", r.LatencyOverflow, max, max)
+		}
+	}
+	return nil
+}
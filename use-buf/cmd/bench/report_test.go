@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordOverflow(t *testing.T) {
+	a, b := newStats(), newStats()
+	a.record(2*time.Millisecond, 1, nil)
+	a.record(90*time.Second, 1, nil)
+	a.record(time.Millisecond, 0, errors.New("failed"))
+	b.record(2*time.Minute, 1, nil)
+	a.merge(b)
+
+	if a.succeeded != 3 || a.overflow != 2 {
+		t.Fatalf("succeeded %d with %d overflows, want 3 with 2", a.succeeded, a.overflow)
+	}
+	if n := a.latency.TotalCount(); n != a.succeeded {
+		t.Errorf("histogram counts %d latencies, want all %d successes", n, a.succeeded)
+	}
+	l := newLatencyReport(a.latency)
+	if l.Max < 59000 || l.P50 < 59000 {
+		t.Errorf("max %.3fms and p50 %.3fms, want the overflows counted as 1 minute", l.Max, l.P50)
+	}
+
+	var out bytes.Buffer
+	writeText(&out, []report{{Succeeded: a.succeeded, Latency: l, LatencyOverflow: a.overflow}})
+	if !strings.Contains(out.String(), "overflow   2 slower than 1m0s") {
+		t.Errorf("report does not show the overflow:\n%s", out.String())
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc"
+
+	hellopb "mygrpc/pkg/grpc"
+)
+
+// RPCs the benchmark can drive.
+const (
+	rpcGreet    = "greet"
+	rpcHello    = "hello"
+	rpcMany     = "many"
+	rpcLong     = "long"
+	rpcEveryone = "everyone"
+)
+
+// caller makes one RPC and returns the number of messages sent or received
+// successfully (1 for a successful unary RPC).
+type caller func(ctx context.Context) (int, error)
+
+type callerConfig struct {
+	// payload is the total length of the names sent in every request.
+	payload int
+	// messages is the number of messages per streaming RPC.
+	messages int
+	// interval is the interval between GreetManyTimes responses.
+	interval time.Duration
+}
+
+// maxNameLen is the maximum length of the names accepted by the servers'
+// validation.
+const maxNameLen = 64
+
+// maxPayload returns the largest payload the requests of rpc can carry
+// without failing validation: the name of HelloRequest, or the first and
+// last name of greet.Greeting.
+func maxPayload(rpc string) int {
+	if rpc == rpcHello {
+		return maxNameLen
+	}
+	return 2 * maxNameLen
+}
+
+// payloadNames splits a payload of size bytes into names that pass
+// validation, filling the first name before the second.
+func payloadNames(size int) (first, last string) {
+	n := size
+	if n > maxNameLen {
+		n = maxNameLen
+	}
+	return strings.Repeat("a", n), strings.Repeat("a", size-n)
+}
+
+func newCaller(rpc string, cc *grpc.ClientConn, cfg callerConfig) (caller, error) {
+	if cfg.payload > maxPayload(rpc) {
+		return nil, fmt.Errorf("payload %dB exceeds the %dB of names %s requests accept", cfg.payload, maxPayload(rpc), rpc)
+	}
+	first, last := payloadNames(cfg.payload)
+	greeting := &greetpb.Greeting{FirstName: first, LastName: last}
+	greet := greetpb.NewGreetServiceClient(cc)
+	switch rpc {
+	case rpcGreet:
+		req := &greetpb.GreetRequest{Greeting: greeting}
+		return func(ctx context.Context) (int, error) {
+			if _, err := greet.Greet(ctx, req); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}, nil
+	case rpcHello:
+		c := hellopb.NewGreetingServiceClient(cc)
+		req := &hellopb.HelloRequest{Name: first}
+		return func(ctx context.Context) (int, error) {
+			if _, err := c.Hello(ctx, req); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}, nil
+	case rpcMany:
+		req := &greetpb.GreetManyTimesRequest{
+			Greeting:   greeting,
+			Count:      int32(cfg.messages),
+			IntervalMs: int32(cfg.interval / time.Millisecond),
+		}
+		return func(ctx context.Context) (int, error) {
+			stream, err := greet.GreetManyTimes(ctx, req)
+			if err != nil {
+				return 0, err
+			}
+			n := 0
+			for {
+				if _, err := stream.Recv(); err == io.EOF {
+					return n, nil
+				} else if err != nil {
+					return n, err
+				}
+				n++
+			}
+		}, nil
+	case rpcLong:
+		req := &greetpb.LongGreetRequest{Greeting: greeting}
+		return func(ctx context.Context) (int, error) {
+			stream, err := greet.LongGreet(ctx)
+			if err != nil {
+				return 0, err
+			}
+			n := 0
+			for ; n < cfg.messages; n++ {
+				if err := stream.Send(req); err != nil {
+					// The status is returned by CloseAndRecv.
+					break
+				}
+			}
+			_, err = stream.CloseAndRecv()
+			return n, err
+		}, nil
+	case rpcEveryone:
+		req := &greetpb.GreetEveryoneRequest{Greeting: greeting}
+		return func(ctx context.Context) (int, error) {
+			stream, err := greet.GreetEveryone(ctx)
+			if err != nil {
+				return 0, err
+			}
+			// Each request waits for its response so that the latency
+			// includes a round trip per message.
+			n := 0
+			for ; n < cfg.messages; n++ {
+				if err := stream.Send(req); err != nil {
+					break
+				}
+				if _, err := stream.Recv(); err != nil {
+					return n, err
+				}
+			}
+			if err := stream.CloseSend(); err != nil {
+				return n, err
+			}
+			if _, err := stream.Recv(); err != io.EOF {
+				if err == nil {
+					err = fmt.Errorf("unexpected response after CloseSend")
+				}
+				return n, err
+			}
+			return n, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown RPC %q", rpc)
+}
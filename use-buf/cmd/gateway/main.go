@@ -0,0 +1,110 @@
+// Command gateway serves the gRPC services of both servers as HTTP/JSON,
+// transcoding requests according to their google.api.http annotations:
+//
+//	POST /v1/hello          greeter.v1.GreetingService/Hello
+//	POST /v1/greet          greet.GreetService/Greet
+//	POST /v1/greet/deadline greet.GreetService/GreetWithDeadline
+//	POST /v1/greet/many     greet.GreetService/GreetManyTimes
+//
+// gRPC status codes are mapped to HTTP status codes with the error details
+// in the body. GreetManyTimes streams one JSON object per line. The
+// Authorization header is forwarded as the bearer token.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/config"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	_ "github.com/hrfmmr/grpc-go-sandbox/lb"
+	"github.com/hrfmmr/grpc-go-sandbox/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	hellopb "mygrpc/pkg/grpc"
+)
+
+func main() {
+	configFile := flag.String("config", "", "YAML config file setting any of these flags by name (or GATEWAY_CONFIG)")
+	addr := flag.String("addr", ":8081", "address to serve HTTP/JSON on")
+	greetingEndpoint := flag.String("greeting-endpoint", "localhost:8080", "plaintext address of the GreetingService server")
+	greetEndpoint := flag.String("greet-endpoint", "localhost:50051", "TLS address of the GreetService server")
+	caFile := flag.String("ca", "../use-self-signed-tls/ssl/ca.crt", "CA certificate to verify the GreetService server with")
+	certFile := flag.String("cert", "", "client certificate file for a GreetService server requiring mutual TLS")
+	keyFile := flag.String("key", "", "client private key file for a GreetService server requiring mutual TLS")
+	flag.Parse()
+	if _, err := config.Load(flag.CommandLine, "GATEWAY", *configFile); err != nil {
+		log.Fatal(err)
+	}
+
+	creds, err := newClientCreds(*caFile, *certFile, *keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mux := web.NewGatewayMux()
+	err = hellopb.RegisterGreetingServiceHandlerFromEndpoint(ctx, mux, *greetingEndpoint,
+		[]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = greetpb.RegisterGreetServiceHandlerFromEndpoint(ctx, mux, *greetEndpoint,
+		[]grpc.DialOption{grpc.WithTransportCredentials(creds)})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		log.Printf("💨 Start HTTP/JSON gateway addr:%v\n", *addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve:%v", err)
+		}
+	}()
+
+	q := make(chan os.Signal, 1)
+	signal.Notify(q, os.Interrupt, syscall.SIGTERM)
+	<-q
+	log.Println("👋 Stopping gateway")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down:%v\n", err)
+	}
+}
+
+func newClientCreds(caFile, certFile, keyFile string) (credentials.TransportCredentials, error) {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	cfg := &tls.Config{
+		RootCAs: pool,
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
+	"github.com/hrfmmr/grpc-go-sandbox/config"
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	"github.com/hrfmmr/grpc-go-sandbox/telemetry"
+	"github.com/hrfmmr/grpc-go-sandbox/web"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	hellopb "mygrpc/pkg/grpc"
@@ -29,24 +41,144 @@ func NewMyGreetingServer() *myGreetingServer {
 }
 
 func main() {
-	port := 8080
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	configFile := flag.String("config", "", "YAML config file setting any of these flags by name (or GREETING_SERVER_CONFIG)")
+	listenAddrs := flag.String("listen", ":8080", "comma separated plaintext addresses to serve on: host:port or unix:///path")
+	metricsAddr := flag.String("metrics-addr", ":9090", "address to serve Prometheus /metrics on (empty to disable; served on the gRPC port with -mux)")
+	traceExporter := flag.String("trace-exporter", telemetry.TraceExporterNone, "trace exporter: none, stdout or otlp")
+	otlpEndpoint := flag.String("otlp-endpoint", "localhost:4317", "OTLP/gRPC collector endpoint (used with -trace-exporter otlp)")
+	grpcWebAddr := flag.String("grpc-web-addr", "", "address to serve plaintext gRPC-Web on, e.g. :8082 (empty to disable)")
+	grpcWebOrigins := flag.String("grpc-web-origins", "", "comma separated origins allowed to call gRPC-Web cross-origin (* for any)")
+	mux := flag.Bool("mux", false, "serve gRPC, gRPC-Web, the REST gateway and /metrics all on the gRPC port in plaintext (h2c)")
+	drainTimeout := flag.Duration("drain-timeout", 10*time.Second, "time to wait for in-flight RPCs served over HTTP to finish on shutdown before stopping them")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	slog.SetDefault(logger)
+
+	cfg, err := config.Load(flag.CommandLine, "GREETING_SERVER", *configFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	listeners, err := config.ParseListeners(*listenAddrs, false, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Log(logger)
+
+	var liss []net.Listener
+	for _, l := range listeners {
+		lis, err := l.Listen()
+		if err != nil {
+			log.Fatal(err)
+		}
+		liss = append(liss, lis)
+	}
+
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		ServiceName:  "greeting-server",
+		Exporter:     *traceExporter,
+		OTLPEndpoint: *otlpEndpoint,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reg := telemetry.NewRegistry()
+	metrics := interceptor.NewMetrics(reg)
+	var metricsSrv *http.Server
+	if !*mux && *metricsAddr != "" {
+		metricsSrv = telemetry.ServeMetrics(*metricsAddr, reg)
+	}
 
-	s := grpc.NewServer()
+	rv, err := newRequestValidator(&hellopb.HelloRequest{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := append(interceptor.ServerOptions(logger, metrics),
+		grpc.ChainUnaryInterceptor(rv.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(rv.StreamInterceptor),
+		telemetry.ServerStatsHandler(),
+	)
+	s := grpc.NewServer(opts...)
 	hellopb.RegisterGreetingServiceServer(s, NewMyGreetingServer())
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
 	reflection.Register(s)
+	for service := range s.GetServiceInfo() {
+		hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
 
-	go func() {
-		log.Printf("💨 Start gRPC server port:%+v\n", port)
-		s.Serve(listener)
-	}()
+	origins := splitList(*grpcWebOrigins)
+	// Tracks the RPCs served through HTTP handlers, which GracefulStop
+	// cannot drain.
+	tracker := &web.Tracker{}
+	var webSrv *http.Server
+	var muxSrvs []*http.Server
+	// The REST gateway calls back into s; its connection is closed before
+	// the server is shut down so that it does not hold the shutdown.
+	gwCtx, gwCancel := context.WithCancel(context.Background())
+	if *mux {
+		gw := web.NewGatewayMux()
+		err := hellopb.RegisterGreetingServiceHandlerFromEndpoint(gwCtx, gw, listeners[0].DialTarget(),
+			[]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())})
+		if err != nil {
+			log.Fatal(err)
+		}
+		hm := http.NewServeMux()
+		hm.Handle("/metrics", telemetry.MetricsHandler(reg))
+		hm.Handle("/", gw)
+		h := tracker.Wrap(web.Multiplex(s, web.NewHandler(s, origins, hm)))
+		for i, lis := range liss {
+			log.Printf("💨 Start gRPC, gRPC-Web, REST and metrics server listen:%v\n", listeners[i])
+			muxSrvs = append(muxSrvs, web.ServeH2C(lis, h, tracker))
+		}
+	} else {
+		if *grpcWebAddr != "" {
+			webSrv = web.Serve(*grpcWebAddr, tracker.Wrap(web.NewHandler(s, origins, nil)), nil)
+		}
+		for i, lis := range liss {
+			log.Printf("💨 Start gRPC server listen:%v\n", listeners[i])
+			go s.Serve(lis)
+		}
+	}
 
 	q := make(chan os.Signal, 1)
 	signal.Notify(q, os.Interrupt)
 	<-q
 	log.Println("👋 Stopping gRPC server")
-	s.GracefulStop()
+	hs.Shutdown()
+	gwCancel()
+	// RPCs served through HTTP handlers cannot be drained by GracefulStop,
+	// so the HTTP servers are shut down and their RPCs drained first.
+	ctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	https := muxSrvs
+	if webSrv != nil {
+		https = append(https, webSrv)
+	}
+	for _, srv := range https {
+		srv.Shutdown(ctx)
+	}
+	if err := tracker.Drain(ctx); err != nil {
+		log.Printf("Drain timeout %v exceeded, stopping in-flight RPCs\n", *drainTimeout)
+		s.Stop()
+	} else {
+		s.GracefulStop()
+	}
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Printf("Failed to flush traces:%v\n", err)
+	}
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestValidator enforces the buf.validate constraints declared in the
+// proto files on every request, reporting violations as
+// codes.InvalidArgument with google.rpc.BadRequest details.
+type requestValidator struct {
+	v *protovalidate.Validator
+}
+
+func newRequestValidator(msgs ...proto.Message) (*requestValidator, error) {
+	v, err := protovalidate.New(protovalidate.WithMessages(msgs...))
+	if err != nil {
+		return nil, err
+	}
+	return &requestValidator{v: v}, nil
+}
+
+func (rv *requestValidator) check(m interface{}) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	err := rv.v.Validate(pm)
+	if err == nil {
+		return nil
+	}
+	var verr *protovalidate.ValidationError
+	if !errors.As(err, &verr) {
+		return status.Errorf(codes.Internal, "validate request:%v", err)
+	}
+	br := &errdetails.BadRequest{}
+	for _, v := range verr.Violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.GetFieldPath(),
+			Description: v.GetMessage(),
+		})
+	}
+	st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(br)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request")
+	}
+	return st.Err()
+}
+
+// UnaryInterceptor rejects invalid requests before they reach the handler.
+func (rv *requestValidator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := rv.check(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor checks every message received on a stream.
+func (rv *requestValidator) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingStream{ServerStream: ss, rv: rv})
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	rv *requestValidator
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.rv.check(m)
+}
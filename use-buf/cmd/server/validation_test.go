@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/validate"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	hellopb "mygrpc/pkg/grpc"
+)
+
+// TestNameRuleMatchesGreetService checks that HelloRequest.name declares the
+// person name rule that GreetService enforces.
+func TestNameRuleMatchesGreetService(t *testing.T) {
+	fd := (&hellopb.HelloRequest{}).ProtoReflect().Descriptor().Fields().ByName("name")
+	opts := fd.Options().(*descriptorpb.FieldOptions)
+	rules := proto.GetExtension(opts, validatepb.E_Field).(*validatepb.FieldConstraints).GetString_()
+	if got := rules.GetPattern(); got != validate.NamePattern {
+		t.Errorf("HelloRequest.name pattern = %q, want validate.NamePattern %q", got, validate.NamePattern)
+	}
+	if got := rules.GetMaxLen(); got != validate.NameMaxLen {
+		t.Errorf("HelloRequest.name max_len = %d, want validate.NameMaxLen %d", got, validate.NameMaxLen)
+	}
+}
+
+func TestNameRuleAgreement(t *testing.T) {
+	rv, err := newRequestValidator(&hellopb.HelloRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"John",
+		"José",
+		"Jose\u0301",   // e with a combining acute accent
+		"Zoe\u0308y",   // combining diaeresis
+		"\u0915\u093f", // Devanagari ki, with a spacing combining mark
+		"O'Brien",
+		"Anne-Marie",
+		"J. R. R.",
+		"John ",
+		strings.Repeat("a", validate.NameMaxLen),
+		"",
+		" ",
+		" John",
+		"-John",
+		"\u0301John",
+		"John3",
+		"<script>",
+		"John_Doe",
+		strings.Repeat("a", validate.NameMaxLen+1),
+		strings.Repeat("é", validate.NameMaxLen+1),
+	} {
+		helloErr := rv.check(&hellopb.HelloRequest{Name: name})
+		// first_name is required as name is by its min_len.
+		greetErr := validate.NameRules.Check(&greetpb.GreetRequest{Greeting: &greetpb.Greeting{FirstName: name}})
+		if (helloErr == nil) != (greetErr == nil) {
+			t.Errorf("name %q: GreetingService error %v, GreetService error %v", name, helloErr, greetErr)
+		}
+	}
+}
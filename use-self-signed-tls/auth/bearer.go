@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// bearerCreds attaches a bearer token to every RPC.
+type bearerCreds struct {
+	token string
+}
+
+// NewBearerToken returns per-RPC credentials sending token in the
+// "authorization" metadata. The token is only sent over a secure transport.
+func NewBearerToken(token string) credentials.PerRPCCredentials {
+	return &bearerCreds{token: token}
+}
+
+// BearerTokenFromFile is NewBearerToken with the token read from path.
+func BearerTokenFromFile(path string) (credentials.PerRPCCredentials, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBearerToken(strings.TrimSpace(string(b))), nil
+}
+
+func (c *bearerCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c *bearerCreds) RequireTransportSecurity() bool {
+	return true
+}
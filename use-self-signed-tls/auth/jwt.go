@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// clockSkew is the leeway allowed when checking exp, nbf and iat.
+const clockSkew = 30 * time.Second
+
+// Claims are the validated claims of a bearer token.
+type Claims struct {
+	jwt.Claims
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+}
+
+// HasRole reports whether the claims grant role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the bearer token validated for the
+// RPC.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// JWTConfig configures a JWTValidator.
+type JWTConfig struct {
+	// JWKSFile is a JSON Web Key Set holding the public keys tokens are
+	// signed with.
+	JWKSFile string
+	// Issuer is the expected "iss" claim.
+	Issuer string
+	// Audience is the expected "aud" claim.
+	Audience string
+	// SkipMethods are full method name prefixes callable without a token,
+	// e.g. "/grpc.health.v1.Health/".
+	SkipMethods []string
+}
+
+// JWTValidator authenticates RPCs by the bearer token in the "authorization"
+// metadata.
+type JWTValidator struct {
+	cfg  JWTConfig
+	keys jose.JSONWebKeySet
+	now  func() time.Time
+}
+
+// NewJWTValidator loads the key set of cfg.JWKSFile.
+func NewJWTValidator(cfg JWTConfig) (*JWTValidator, error) {
+	b, err := ioutil.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("parse %s:%v", cfg.JWKSFile, err)
+	}
+	if len(keys.Keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", cfg.JWKSFile)
+	}
+	return &JWTValidator{cfg: cfg, keys: keys, now: time.Now}, nil
+}
+
+// Validate verifies the signature, expiry, issuer and audience of token.
+func (v *JWTValidator) Validate(token string) (*Claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+	h := tok.Headers[0]
+	keys := v.keys.Key(h.KeyID)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("unknown key id:%q", h.KeyID)
+	}
+	key := keys[0]
+	if !key.IsPublic() {
+		key = key.Public()
+	}
+	if key.Algorithm != "" && key.Algorithm != h.Algorithm {
+		return nil, fmt.Errorf("unexpected signing algorithm:%q", h.Algorithm)
+	}
+	claims := &Claims{}
+	if err := tok.Claims(key, claims); err != nil {
+		return nil, err
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:   v.cfg.Issuer,
+		Audience: jwt.Audience{v.cfg.Audience},
+		Time:     v.now(),
+	}, clockSkew)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTValidator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	for _, p := range v.cfg.SkipMethods {
+		if strings.HasPrefix(method, p) {
+			return ctx, nil
+		}
+	}
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := v.Validate(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid bearer token:%v", err)
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	const prefix = "bearer "
+	if len(values[0]) <= len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return "", status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+	return values[0][len(prefix):], nil
+}
+
+// UnaryInterceptor returns the interceptor rejecting unauthenticated unary
+// RPCs with codes.Unauthenticated. Handlers read the claims with
+// ClaimsFromContext.
+func (v *JWTValidator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := v.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is the stream counterpart of UnaryInterceptor.
+func (v *JWTValidator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := v.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
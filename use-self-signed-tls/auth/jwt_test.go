@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testKeyID    = "test-key"
+	testIssuer   = "greet-issuer"
+	testAudience = "greet-server"
+	testMethod   = "/greet.GreetService/Greet"
+)
+
+// testNow is the time the validator under test checks tokens at.
+var testNow = time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newTestValidator returns a validator trusting the public half of key under
+// testKeyID, as cmd/jwtgen writes it.
+func newTestValidator(t *testing.T, key *ecdsa.PrivateKey) *JWTValidator {
+	t.Helper()
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       key.Public(),
+		KeyID:     testKeyID,
+		Algorithm: string(jose.ES256),
+		Use:       "sig",
+	}}}
+	b, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(file, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewJWTValidator(JWTConfig{
+		JWKSFile:    file,
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		SkipMethods: []string{"/grpc.health.v1.Health/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+// validClaims are claims the test validator accepts.
+func validClaims() *Claims {
+	return &Claims{
+		Claims: jwt.Claims{
+			Subject:  "alice",
+			Issuer:   testIssuer,
+			Audience: jwt.Audience{testAudience},
+			IssuedAt: jwt.NewNumericDate(testNow.Add(-time.Minute)),
+			Expiry:   jwt.NewNumericDate(testNow.Add(time.Hour)),
+		},
+		Roles: []string{"admin"},
+	}
+}
+
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, kid string, claims *Claims) string {
+	t.Helper()
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader("kid", kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// unsigned returns claims as a token with the "none" algorithm.
+func unsigned(t *testing.T, claims *Claims) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "none", "kid": testKeyID, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(header) + "." + enc.EncodeToString(payload) + "."
+}
+
+// call runs the unary interceptor of v on method with md as the incoming
+// metadata, and returns the claims the handler saw.
+func call(v *JWTValidator, method string, md metadata.MD) (*Claims, error) {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	var claims *Claims
+	_, err := v.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		claims, _ = ClaimsFromContext(ctx)
+		return nil, nil
+	})
+	return claims, err
+}
+
+func bearer(token string) metadata.MD {
+	return metadata.Pairs("authorization", "Bearer "+token)
+}
+
+func TestJWTValidator(t *testing.T) {
+	key := generateKey(t)
+	v := newTestValidator(t, key)
+	with := func(modify func(*Claims)) *Claims {
+		c := validClaims()
+		modify(c)
+		return c
+	}
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := call(v, testMethod, bearer(sign(t, jose.ES256, key, testKeyID, validClaims())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims == nil {
+			t.Fatal("no claims in the handler context")
+		}
+		if claims.Subject != "alice" || !claims.HasRole("admin") {
+			t.Errorf("claims = %+v, want subject alice with role admin", claims)
+		}
+	})
+
+	t.Run("expired within leeway", func(t *testing.T) {
+		token := sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Expiry = jwt.NewNumericDate(testNow.Add(-clockSkew / 2))
+		}))
+		if _, err := call(v, testMethod, bearer(token)); err != nil {
+			t.Errorf("token expired %v ago rejected:%v", clockSkew/2, err)
+		}
+	})
+
+	t.Run("skipped method", func(t *testing.T) {
+		if _, err := call(v, "/grpc.health.v1.Health/Check", nil); err != nil {
+			t.Errorf("skipped method without token rejected:%v", err)
+		}
+	})
+
+	for _, tt := range []struct {
+		name string
+		md   metadata.MD
+	}{
+		{"wrong key", bearer(sign(t, jose.ES256, generateKey(t), testKeyID, validClaims()))},
+		{"unknown kid", bearer(sign(t, jose.ES256, key, "other-key", validClaims()))},
+		{"no kid", bearer(sign(t, jose.ES256, key, "", validClaims()))},
+		{"alg none", bearer(unsigned(t, validClaims()))},
+		// HS256 keyed with the public key, as if the verifier trusted alg.
+		{"alg HS256", bearer(sign(t, jose.HS256, pub, testKeyID, validClaims()))},
+		{"expired", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Expiry = jwt.NewNumericDate(testNow.Add(-2 * clockSkew))
+		})))},
+		{"missing exp", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Expiry = nil
+		})))},
+		{"not yet valid", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.NotBefore = jwt.NewNumericDate(testNow.Add(2 * clockSkew))
+		})))},
+		{"wrong issuer", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Issuer = "other-issuer"
+		})))},
+		{"missing issuer", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Issuer = ""
+		})))},
+		{"wrong audience", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Audience = jwt.Audience{"other-server"}
+		})))},
+		{"missing audience", bearer(sign(t, jose.ES256, key, testKeyID, with(func(c *Claims) {
+			c.Audience = nil
+		})))},
+		{"missing authorization", nil},
+		{"empty authorization", metadata.Pairs("authorization", "")},
+		{"basic authorization", metadata.Pairs("authorization", "Basic YWxpY2U6c2VjcmV0")},
+		{"bearer without token", metadata.Pairs("authorization", "Bearer ")},
+		{"token without scheme", metadata.Pairs("authorization", sign(t, jose.ES256, key, testKeyID, validClaims()))},
+		{"malformed token", bearer("not.a.token")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := call(v, testMethod, tt.md)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("got %v, want Unauthenticated", err)
+			}
+			if claims != nil {
+				t.Errorf("handler called with claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestJWTValidatorStream(t *testing.T) {
+	key := generateKey(t)
+	v := newTestValidator(t, key)
+	for _, tt := range []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{"valid token", bearer(sign(t, jose.ES256, key, testKeyID, validClaims())), codes.OK},
+		{"missing authorization", nil, codes.Unauthenticated},
+		{"wrong key", bearer(sign(t, jose.ES256, generateKey(t), testKeyID, validClaims())), codes.Unauthenticated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := &testStream{ctx: metadata.NewIncomingContext(context.Background(), tt.md)}
+			var claims *Claims
+			err := v.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: testMethod}, func(srv interface{}, ss grpc.ServerStream) error {
+				claims, _ = ClaimsFromContext(ss.Context())
+				return nil
+			})
+			if status.Code(err) != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if (claims != nil) != (tt.want == codes.OK) {
+				t.Errorf("claims in the handler context = %+v", claims)
+			}
+		})
+	}
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
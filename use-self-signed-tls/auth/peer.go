@@ -0,0 +1,49 @@
+// Package auth provides helpers to identify the caller of an RPC.
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity is the caller identity taken from a verified client certificate.
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	URIs       []string
+	Emails     []string
+}
+
+// PeerIdentity returns the identity of the verified client certificate of the
+// peer stored in ctx. It reports false when the connection is not mutual TLS
+// or the client certificate has not been verified.
+func PeerIdentity(ctx context.Context) (*Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	chains := tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil, false
+	}
+	return identityFromCert(chains[0][0]), true
+}
+
+func identityFromCert(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		Emails:     cert.EmailAddresses,
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Engine authorizes RPCs against the policy loaded from a file. The policy
+// can be swapped at runtime with Watch; RPCs in flight keep the decision
+// they were made with.
+type Engine struct {
+	file    string
+	policy  atomic.Pointer[Policy]
+	modTime atomic.Int64
+}
+
+// NewEngine loads the policy of file.
+func NewEngine(file string) (*Engine, error) {
+	e := &Engine{file: file}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Policy returns the active policy.
+func (e *Engine) Policy() *Policy {
+	return e.policy.Load()
+}
+
+func (e *Engine) reload() error {
+	fi, err := os.Stat(e.file)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(e.file)
+	if err != nil {
+		return err
+	}
+	p, err := ParsePolicy(b)
+	if err != nil {
+		return err
+	}
+	e.policy.Store(p)
+	e.modTime.Store(fi.ModTime().UnixNano())
+	log.Printf("🛡 Loaded authorization policy:%q deny_rules:%d allow_rules:%d\n", p.Name, len(p.DenyRules), len(p.AllowRules))
+	return nil
+}
+
+func (e *Engine) changed() bool {
+	fi, err := os.Stat(e.file)
+	if err != nil {
+		log.Printf("Failed to stat authorization policy:%v\n", err)
+		return false
+	}
+	return fi.ModTime().UnixNano() > e.modTime.Load()
+}
+
+// Watch reloads the policy whenever the file changes, polling every
+// interval, or on SIGHUP. A zero interval disables polling. On failure the
+// previous policy stays active. Watch returns when done is closed.
+func (e *Engine) Watch(interval time.Duration, done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading authorization policy")
+		case <-tick:
+			if !e.changed() {
+				continue
+			}
+			log.Println("Authorization policy changed, reloading")
+		}
+		if err := e.reload(); err != nil {
+			log.Printf("Failed to reload authorization policy, keeping %q err:%v\n", e.Policy().Name, err)
+		}
+	}
+}
+
+// Principals returns the principals of the caller of the RPC in ctx as
+// matched by policy rules.
+func Principals(ctx context.Context) []string {
+	var ps []string
+	if c, ok := auth.ClaimsFromContext(ctx); ok {
+		if c.Subject != "" {
+			ps = append(ps, "sub:"+c.Subject)
+		}
+		for _, r := range c.Roles {
+			ps = append(ps, "role:"+r)
+		}
+	}
+	if id, ok := auth.PeerIdentity(ctx); ok {
+		if id.CommonName != "" {
+			ps = append(ps, "cn:"+id.CommonName)
+		}
+		for _, names := range [][]string{id.DNSNames, id.URIs, id.Emails} {
+			for _, n := range names {
+				ps = append(ps, "san:"+n)
+			}
+		}
+	}
+	return ps
+}
+
+func (e *Engine) authorize(ctx context.Context, method string) error {
+	ps := Principals(ctx)
+	d := e.Policy().Evaluate(method, ps)
+	if d.Allowed {
+		return nil
+	}
+	log.Printf("Denied %v principals:%v rule:%q\n", method, ps, d.Rule)
+	return status.Errorf(codes.PermissionDenied, "permission denied:%v", method)
+}
+
+// UnaryInterceptor returns the interceptor rejecting unary RPCs the policy
+// does not allow with codes.PermissionDenied. It must run after the
+// authentication interceptors.
+func (e *Engine) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := e.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is the stream counterpart of UnaryInterceptor.
+func (e *Engine) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := e.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,160 @@
+// Package authz authorizes RPCs against a declarative per-method policy.
+//
+// The policy follows the shape of gRPC authorization policies: a request is
+// denied if it matches any deny rule, allowed if it matches any allow rule,
+// and denied otherwise. A rule matches when both its source principals and
+// its request paths match.
+//
+// Principals are the caller identities established by the authentication
+// layers:
+//
+//	sub:<subject>  subject of the bearer token
+//	role:<role>    role granted by the bearer token
+//	cn:<name>      common name of the verified client certificate
+//	san:<name>     DNS, URI or email SAN of the verified client certificate
+//	*              any caller, authenticated or not
+//
+// Paths are full method names such as "/greet.GreetService/Greet". A
+// trailing "*" matches any suffix, e.g. "/greet.GreetService/*".
+//
+// Every rule must list its principals and paths: write "*" to match any
+// caller or method. Unknown keys are rejected, so that a misspelled key
+// cannot leave a list empty and widen a rule.
+package authz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the authorization policy. It is read from YAML or JSON.
+type Policy struct {
+	Name       string `yaml:"name"`
+	DenyRules  []Rule `yaml:"deny_rules"`
+	AllowRules []Rule `yaml:"allow_rules"`
+}
+
+// Rule matches requests by their caller and method.
+type Rule struct {
+	Name    string  `yaml:"name"`
+	Source  Source  `yaml:"source"`
+	Request Request `yaml:"request"`
+}
+
+// Source lists the principals a rule applies to.
+type Source struct {
+	Principals []string `yaml:"principals"`
+}
+
+// Request lists the methods a rule applies to.
+type Request struct {
+	Paths []string `yaml:"paths"`
+}
+
+// ParsePolicy parses a YAML or JSON policy.
+func ParsePolicy(b []byte) (*Policy, error) {
+	p := &Policy{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("policy is empty")
+		}
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	if p.Name == "" {
+		return errors.New("policy name is required")
+	}
+	if len(p.AllowRules) == 0 {
+		return errors.New("policy has no allow rules")
+	}
+	for _, rules := range [][]Rule{p.DenyRules, p.AllowRules} {
+		for i, r := range rules {
+			if r.Name == "" {
+				return fmt.Errorf("rule #%d has no name", i)
+			}
+			if len(r.Source.Principals) == 0 {
+				return fmt.Errorf("rule %q has no principals, write \"*\" to match any caller", r.Name)
+			}
+			if len(r.Request.Paths) == 0 {
+				return fmt.Errorf("rule %q has no paths, write \"*\" to match any method", r.Name)
+			}
+			for _, path := range r.Request.Paths {
+				if !strings.HasPrefix(path, "/") && path != "*" {
+					return fmt.Errorf("rule %q: path %q must start with /", r.Name, path)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Decision is the result of evaluating a policy.
+type Decision struct {
+	Allowed bool
+	// Rule is the name of the matching rule, or "" when no rule matched.
+	Rule string
+}
+
+// Evaluate decides whether principals may call method.
+func (p *Policy) Evaluate(method string, principals []string) Decision {
+	for _, r := range p.DenyRules {
+		if r.matches(method, principals) {
+			return Decision{Allowed: false, Rule: r.Name}
+		}
+	}
+	for _, r := range p.AllowRules {
+		if r.matches(method, principals) {
+			return Decision{Allowed: true, Rule: r.Name}
+		}
+	}
+	return Decision{Allowed: false}
+}
+
+func (r *Rule) matches(method string, principals []string) bool {
+	return r.Request.matches(method) && r.Source.matches(principals)
+}
+
+func (s *Source) matches(principals []string) bool {
+	for _, want := range s.Principals {
+		if want == "*" {
+			return true
+		}
+		for _, got := range principals {
+			if match(want, got) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Request) matches(method string) bool {
+	for _, p := range r.Paths {
+		if match(p, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether s matches pattern, where a trailing "*" in pattern
+// matches any suffix.
+func match(pattern, s string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(s, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == s
+}
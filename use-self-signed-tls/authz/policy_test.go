@@ -0,0 +1,168 @@
+package authz
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+const (
+	greet             = "/greet.GreetService/Greet"
+	greetManyTimes    = "/greet.GreetService/GreetManyTimes"
+	longGreet         = "/greet.GreetService/LongGreet"
+	greetEveryone     = "/greet.GreetService/GreetEveryone"
+	greetWithDeadline = "/greet.GreetService/GreetWithDeadline"
+)
+
+func loadSamplePolicy(t *testing.T) *Policy {
+	t.Helper()
+	b, err := ioutil.ReadFile("../config/authz_policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePolicy(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEvaluateSamplePolicy(t *testing.T) {
+	p := loadSamplePolicy(t)
+	var (
+		anonymous = []string(nil)
+		user      = []string{"sub:alice"}
+		viewer    = []string{"sub:bob", "role:viewer"}
+		admin     = []string{"sub:carol", "role:admin"}
+		roleOnly  = []string{"role:admin"}
+		client    = []string{"cn:greet-client", "san:localhost"}
+	)
+	tests := []struct {
+		name       string
+		method     string
+		principals []string
+		want       Decision
+	}{
+		{"user Greet", greet, user, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"user GreetManyTimes", greetManyTimes, user, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"user LongGreet", longGreet, user, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"user GreetWithDeadline", greetWithDeadline, user, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"user GreetEveryone", greetEveryone, user, Decision{Allowed: false}},
+		{"viewer GreetEveryone", greetEveryone, viewer, Decision{Allowed: false}},
+		{"viewer Greet", greet, viewer, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+
+		{"admin Greet", greet, admin, Decision{Allowed: true, Rule: "allow-admin-all"}},
+		{"admin GreetManyTimes", greetManyTimes, admin, Decision{Allowed: true, Rule: "allow-admin-all"}},
+		{"admin LongGreet", longGreet, admin, Decision{Allowed: true, Rule: "allow-admin-all"}},
+		{"admin GreetEveryone", greetEveryone, admin, Decision{Allowed: true, Rule: "allow-admin-all"}},
+		{"admin GreetWithDeadline", greetWithDeadline, admin, Decision{Allowed: true, Rule: "allow-admin-all"}},
+		{"admin role without subject GreetEveryone", greetEveryone, roleOnly, Decision{Allowed: true, Rule: "allow-admin-all"}},
+
+		{"client certificate Greet", greet, client, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"client certificate LongGreet", longGreet, client, Decision{Allowed: true, Rule: "allow-authenticated-greet"}},
+		{"client certificate GreetEveryone", greetEveryone, client, Decision{Allowed: false}},
+
+		{"anonymous Greet", greet, anonymous, Decision{Allowed: false}},
+		{"anonymous GreetManyTimes", greetManyTimes, anonymous, Decision{Allowed: false}},
+		{"anonymous LongGreet", longGreet, anonymous, Decision{Allowed: false}},
+		{"anonymous GreetEveryone", greetEveryone, anonymous, Decision{Allowed: false}},
+		{"anonymous GreetWithDeadline", greetWithDeadline, anonymous, Decision{Allowed: false}},
+		{"anonymous health", "/grpc.health.v1.Health/Check", anonymous, Decision{Allowed: true, Rule: "allow-health-and-reflection"}},
+		{"anonymous reflection", "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", anonymous, Decision{Allowed: true, Rule: "allow-health-and-reflection"}},
+
+		{"user unknown method", "/greet.GreetService/Unknown", user, Decision{Allowed: false}},
+		{"user other service", "/other.Service/Greet", user, Decision{Allowed: false}},
+		{"admin other service", "/other.Service/Greet", admin, Decision{Allowed: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Evaluate(tt.method, tt.principals); got != tt.want {
+				t.Errorf("Evaluate(%q, %q) = %+v, want %+v", tt.method, tt.principals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateDenyRules(t *testing.T) {
+	p, err := ParsePolicy([]byte(`
+name: deny-first
+deny_rules:
+  - name: deny-mallory
+    source:
+      principals: [sub:mallory]
+    request:
+      paths: ["*"]
+  - name: deny-guest-streams
+    source:
+      principals: [role:guest]
+    request:
+      paths: [/greet.GreetService/LongGreet, /greet.GreetService/GreetEveryone]
+allow_rules:
+  - name: allow-any-subject
+    source:
+      principals: [sub:*]
+    request:
+      paths: ["*"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		method     string
+		principals []string
+		want       Decision
+	}{
+		{"denied subject", greet, []string{"sub:mallory"}, Decision{Allowed: false, Rule: "deny-mallory"}},
+		{"denied subject with allowed role", greet, []string{"sub:mallory", "role:admin"}, Decision{Allowed: false, Rule: "deny-mallory"}},
+		{"denied role stream", longGreet, []string{"sub:dave", "role:guest"}, Decision{Allowed: false, Rule: "deny-guest-streams"}},
+		{"denied role other stream", greetEveryone, []string{"sub:dave", "role:guest"}, Decision{Allowed: false, Rule: "deny-guest-streams"}},
+		{"denied role unary", greet, []string{"sub:dave", "role:guest"}, Decision{Allowed: true, Rule: "allow-any-subject"}},
+		{"allowed subject", greetEveryone, []string{"sub:dave"}, Decision{Allowed: true, Rule: "allow-any-subject"}},
+		{"default deny", greet, []string{"cn:greet-client"}, Decision{Allowed: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Evaluate(tt.method, tt.principals); got != tt.want {
+				t.Errorf("Evaluate(%q, %q) = %+v, want %+v", tt.method, tt.principals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	p, err := ParsePolicy([]byte(`{"name": "p", "allow_rules": [{"name": "any", "source": {"principals": ["*"]}, "request": {"paths": ["*"]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Decision{Allowed: true, Rule: "any"}
+	if got := p.Evaluate(greetEveryone, nil); got != want {
+		t.Errorf("Evaluate(%q, nil) = %+v, want %+v", greetEveryone, got, want)
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, policy string
+	}{
+		{"empty", ""},
+		{"no name", "allow_rules: [{name: a, source: {principals: ['*']}, request: {paths: ['*']}}]"},
+		{"no allow rules", "name: p"},
+		{"unnamed rule", "name: p\nallow_rules: [{source: {principals: ['*']}, request: {paths: ['*']}}]"},
+		{"relative path", "name: p\nallow_rules: [{name: a, source: {principals: ['*']}, request: {paths: [greet.GreetService/Greet]}}]"},
+		{"no principals", "name: p\nallow_rules: [{name: a, request: {paths: ['*']}}]"},
+		{"empty principals", "name: p\nallow_rules: [{name: a, source: {principals: []}, request: {paths: ['*']}}]"},
+		{"no paths", "name: p\nallow_rules: [{name: a, source: {principals: [role:admin]}}]"},
+		{"deny rule without principals", "name: p\ndeny_rules: [{name: d, request: {paths: ['*']}}]\nallow_rules: [{name: a, source: {principals: ['*']}, request: {paths: ['*']}}]"},
+		{"misspelled principals", "name: p\nallow_rules: [{name: a, source: {principal: [role:admin]}, request: {paths: ['*']}}]"},
+		{"misspelled source", "name: p\nallow_rules: [{name: a, sources: {principals: [role:admin]}, request: {paths: ['*']}}]"},
+		{"misspelled paths", "name: p\nallow_rules: [{name: a, source: {principals: [role:admin]}, request: {path: [/greet.GreetService/GreetEveryone]}}]"},
+		{"misspelled rules", "name: p\nallow_rule: [{name: a, source: {principals: [role:admin]}, request: {paths: ['*']}}]"},
+		{"misspelled JSON key", `{"name": "p", "allow_rules": [{"name": "a", "source": {"principal": ["role:admin"]}, "request": {"paths": ["*"]}}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePolicy([]byte(tt.policy)); err == nil {
+				t.Errorf("ParsePolicy(%q) succeeded, want error", tt.policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("no certificate in %s", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// checkKey checks that the key file is private, unencrypted PKCS#8 of the
+// key type and that it pairs with the certificate.
+func checkKey(t *testing.T, kt keyType, certFile, keyFile string) {
+	t.Helper()
+	fi, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("%s mode = %v, want 0600", keyFile, perm)
+	}
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("no unencrypted PKCS#8 key in %s", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		if kt != keyTypeRSA {
+			t.Errorf("%s is an RSA key, want %s", keyFile, kt)
+		}
+	case *ecdsa.PrivateKey:
+		if kt != keyTypeECDSA {
+			t.Errorf("%s is an ECDSA key, want %s", keyFile, kt)
+		}
+	default:
+		t.Errorf("%s is a %T", keyFile, key)
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		t.Errorf("%s and %s don't pair:%v", certFile, keyFile, err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, kt := range []keyType{keyTypeRSA, keyTypeECDSA} {
+		t.Run(string(kt), func(t *testing.T) {
+			g := &generator{
+				out:      t.TempDir(),
+				keyType:  kt,
+				rsaBits:  2048,
+				validity: 24 * time.Hour,
+			}
+			if err := g.genCA("test-ca", 48*time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			serverSANs := []string{"localhost", "127.0.0.1", "::1", "spiffe://greet/server", "ops@example.com"}
+			if err := g.genLeaf("localhost", serverSANs, x509.ExtKeyUsageServerAuth, serverCSR, serverCert, serverPEM); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.genLeaf("greet-client", nil, x509.ExtKeyUsageClientAuth, clientCSR, clientCert, clientPEM); err != nil {
+				t.Fatal(err)
+			}
+
+			ca := readCert(t, g.path(caCert))
+			if !ca.IsCA || !ca.BasicConstraintsValid || ca.MaxPathLen != 0 || !ca.MaxPathLenZero {
+				t.Errorf("CA basic constraints: IsCA %v, MaxPathLen %d", ca.IsCA, ca.MaxPathLen)
+			}
+			if ca.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+				t.Errorf("CA key usage = %v, want CertSign|CRLSign", ca.KeyUsage)
+			}
+			checkKey(t, kt, g.path(caCert), g.path(caKey))
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+
+			wantUsage := x509.KeyUsageDigitalSignature
+			if kt == keyTypeRSA {
+				wantUsage |= x509.KeyUsageKeyEncipherment
+			}
+			for _, leaf := range []struct {
+				cert, key string
+				usage     x509.ExtKeyUsage
+				other     x509.ExtKeyUsage
+			}{
+				{serverCert, serverPEM, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+				{clientCert, clientPEM, x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+			} {
+				cert := readCert(t, g.path(leaf.cert))
+				if cert.IsCA {
+					t.Errorf("%s is a CA", leaf.cert)
+				}
+				if cert.KeyUsage != wantUsage {
+					t.Errorf("%s key usage = %v, want %v", leaf.cert, cert.KeyUsage, wantUsage)
+				}
+				if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != leaf.usage {
+					t.Errorf("%s extended key usage = %v, want [%v]", leaf.cert, cert.ExtKeyUsage, leaf.usage)
+				}
+				if cert.NotAfter.After(ca.NotAfter) {
+					t.Errorf("%s outlives the CA", leaf.cert)
+				}
+				checkKey(t, kt, g.path(leaf.cert), g.path(leaf.key))
+				opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{leaf.usage}}
+				if _, err := cert.Verify(opts); err != nil {
+					t.Errorf("%s does not chain to the CA:%v", leaf.cert, err)
+				}
+				opts.KeyUsages = []x509.ExtKeyUsage{leaf.other}
+				if _, err := cert.Verify(opts); err == nil {
+					t.Errorf("%s verified for %v", leaf.cert, leaf.other)
+				}
+			}
+
+			server := readCert(t, g.path(serverCert))
+			if got := strings.Join(server.DNSNames, ","); got != "localhost" {
+				t.Errorf("DNS SANs = %v, want [localhost]", server.DNSNames)
+			}
+			if len(server.IPAddresses) != 2 || !server.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) || !server.IPAddresses[1].Equal(net.IPv6loopback) {
+				t.Errorf("IP SANs = %v, want [127.0.0.1 ::1]", server.IPAddresses)
+			}
+			if len(server.URIs) != 1 || server.URIs[0].String() != "spiffe://greet/server" {
+				t.Errorf("URI SANs = %v, want [spiffe://greet/server]", server.URIs)
+			}
+			if got := strings.Join(server.EmailAddresses, ","); got != "ops@example.com" {
+				t.Errorf("email SANs = %v, want [ops@example.com]", server.EmailAddresses)
+			}
+			for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+				if err := server.VerifyHostname(host); err != nil {
+					t.Errorf("VerifyHostname(%s):%v", host, err)
+				}
+			}
+			if err := server.VerifyHostname("example.com"); err == nil {
+				t.Error("server certificate valid for example.com")
+			}
+		})
+	}
+}
+
+// TestReissue checks that a leaf issued by the CA loaded from -out chains to
+// it, as with -issue server.
+func TestReissue(t *testing.T) {
+	g := &generator{out: t.TempDir(), keyType: keyTypeECDSA, validity: time.Hour}
+	if err := g.genCA("test-ca", 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	ca, err := loadAuthority(g.path(caCert), g.path(caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = &generator{out: g.out, keyType: keyTypeRSA, rsaBits: 2048, validity: 48 * time.Hour, ca: ca}
+	if err := g.genLeaf("localhost", []string{"localhost"}, x509.ExtKeyUsageServerAuth, serverCSR, serverCert, serverPEM); err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cert := readCert(t, g.path(serverCert))
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots}); err != nil {
+		t.Errorf("reissued certificate does not chain to the CA:%v", err)
+	}
+	if !cert.NotAfter.Equal(ca.cert.NotAfter) {
+		t.Errorf("NotAfter = %v, want capped at the CA's %v", cert.NotAfter, ca.cert.NotAfter)
+	}
+
+	if _, err := loadAuthority(g.path(caCert), g.path(serverCSR)); err == nil {
+		t.Error("loadAuthority accepted a CSR as the CA key")
+	}
+}
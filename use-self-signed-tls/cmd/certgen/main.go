@@ -0,0 +1,160 @@
+// Command certgen creates a certificate authority and issues the server and
+// client certificates used by the greet server and client.
+//
+// It writes the following files into -out:
+//
+//	ca.key      CA private key (this shouldn't be shared)
+//	ca.crt      CA trust certificate (this should be shared with users)
+//	server.csr  server certificate signing request
+//	server.crt  server certificate signed by the CA
+//	server.pem  server private key (this shouldn't be shared)
+//	client.csr  client certificate signing request
+//	client.crt  client certificate signed by the CA, used for mutual TLS
+//	client.pem  client private key (this shouldn't be shared)
+//
+// Private keys are written as unencrypted PKCS#8, which is what gRPC expects.
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	caKey      = "ca.key"
+	caCert     = "ca.crt"
+	serverCSR  = "server.csr"
+	serverCert = "server.crt"
+	serverPEM  = "server.pem"
+	clientCSR  = "client.csr"
+	clientCert = "client.crt"
+	clientPEM  = "client.pem"
+)
+
+func main() {
+	out := flag.String("out", "ssl", "directory to write certificates and keys into")
+	issue := flag.String("issue", "ca,server,client", "comma separated list of ca, server and client to issue; without ca the existing CA in -out signs the certificates")
+	kt := flag.String("key-type", "rsa", "private key algorithm: rsa or ecdsa (P-256)")
+	rsaBits := flag.Int("rsa-bits", 4096, "RSA key size")
+	caCN := flag.String("ca-cn", "greet-ca", "CA common name")
+	caValidity := flag.Duration("ca-validity", 10*365*24*time.Hour, "CA certificate validity")
+	serverCN := flag.String("server-cn", "localhost", "server common name")
+	serverSANs := flag.String("server-sans", "localhost,127.0.0.1,::1", "comma separated server subject alternative names (DNS, IP, URI or email)")
+	clientCN := flag.String("client-cn", "greet-client", "client common name")
+	clientSANs := flag.String("client-sans", "", "comma separated client subject alternative names (DNS, IP, URI or email)")
+	validity := flag.Duration("validity", 365*24*time.Hour, "server and client certificate validity")
+	flag.Parse()
+
+	targets := map[string]bool{}
+	for _, t := range splitList(*issue) {
+		switch t {
+		case "ca", "server", "client":
+			targets[t] = true
+		default:
+			log.Fatalf("unknown -issue target:%q", t)
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	g := &generator{
+		out:      *out,
+		keyType:  keyType(*kt),
+		rsaBits:  *rsaBits,
+		validity: *validity,
+	}
+
+	var err error
+	if targets["ca"] {
+		err = g.genCA(*caCN, *caValidity)
+	} else {
+		g.ca, err = loadAuthority(g.path(caCert), g.path(caKey))
+	}
+	if err != nil {
+		log.Fatalf("CA:%v", err)
+	}
+	if targets["server"] {
+		if err := g.genLeaf(*serverCN, splitList(*serverSANs), x509.ExtKeyUsageServerAuth, serverCSR, serverCert, serverPEM); err != nil {
+			log.Fatalf("server:%v", err)
+		}
+	}
+	if targets["client"] {
+		if err := g.genLeaf(*clientCN, splitList(*clientSANs), x509.ExtKeyUsageClientAuth, clientCSR, clientCert, clientPEM); err != nil {
+			log.Fatalf("client:%v", err)
+		}
+	}
+}
+
+type generator struct {
+	out      string
+	keyType  keyType
+	rsaBits  int
+	validity time.Duration
+	ca       *authority
+}
+
+func (g *generator) path(name string) string {
+	return filepath.Join(g.out, name)
+}
+
+func (g *generator) genCA(cn string, validity time.Duration) error {
+	key, err := generateKey(g.keyType, g.rsaBits)
+	if err != nil {
+		return err
+	}
+	ca, der, err := newAuthority(cn, key, validity)
+	if err != nil {
+		return err
+	}
+	if err := writeKey(g.path(caKey), key); err != nil {
+		return err
+	}
+	if err := writePEM(g.path(caCert), "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	g.ca = ca
+	fmt.Printf("Wrote %v and %v (CN=%v)\n", g.path(caCert), g.path(caKey), cn)
+	return nil
+}
+
+func (g *generator) genLeaf(cn string, sans []string, usage x509.ExtKeyUsage, csrFile, certFile, keyFile string) error {
+	key, err := generateKey(g.keyType, g.rsaBits)
+	if err != nil {
+		return err
+	}
+	csrDER, csr, err := newCSR(cn, sans, key)
+	if err != nil {
+		return err
+	}
+	der, err := g.ca.issue(csr, usage, key, g.validity)
+	if err != nil {
+		return err
+	}
+	if err := writeKey(g.path(keyFile), key); err != nil {
+		return err
+	}
+	if err := writePEM(g.path(csrFile), "CERTIFICATE REQUEST", csrDER, 0644); err != nil {
+		return err
+	}
+	if err := writePEM(g.path(certFile), "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v and %v (CN=%v SANs=%v)\n", g.path(certFile), g.path(keyFile), cn, sans)
+	return nil
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
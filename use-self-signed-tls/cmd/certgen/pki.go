@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// keyType is the algorithm of generated private keys.
+type keyType string
+
+const (
+	keyTypeRSA   keyType = "rsa"
+	keyTypeECDSA keyType = "ecdsa"
+)
+
+func generateKey(kt keyType, rsaBits int) (crypto.Signer, error) {
+	switch kt {
+	case keyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, rsaBits)
+	case keyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unknown key type:%q", kt)
+	}
+}
+
+// keyUsage returns the key usage of a leaf certificate. Key encipherment is
+// only meaningful for RSA key exchange.
+func keyUsage(key crypto.Signer) x509.KeyUsage {
+	ku := x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		ku |= x509.KeyUsageKeyEncipherment
+	}
+	return ku
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// subjectAltNames sorts each name into DNS, IP, URI or email SANs.
+func subjectAltNames(tmpl *x509.CertificateRequest, names []string) error {
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		switch {
+		case n == "":
+			continue
+		case net.ParseIP(n) != nil:
+			tmpl.IPAddresses = append(tmpl.IPAddresses, net.ParseIP(n))
+		case strings.Contains(n, "://"):
+			u, err := url.Parse(n)
+			if err != nil {
+				return err
+			}
+			tmpl.URIs = append(tmpl.URIs, u)
+		case strings.Contains(n, "@"):
+			tmpl.EmailAddresses = append(tmpl.EmailAddresses, n)
+		default:
+			tmpl.DNSNames = append(tmpl.DNSNames, n)
+		}
+	}
+	return nil
+}
+
+// authority is a CA able to sign leaf certificates.
+type authority struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newAuthority(cn string, key crypto.Signer, validity time.Duration) (*authority, []byte, error) {
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &authority{cert: cert, key: key}, der, nil
+}
+
+func loadAuthority(certFile, keyFile string) (*authority, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no unencrypted PKCS#8 private key found in %s", keyFile)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("CA private key cannot sign")
+	}
+	return &authority{cert: cert, key: key}, nil
+}
+
+// issue signs a certificate for csr valid for the given extended key usage.
+func (a *authority) issue(csr *x509.CertificateRequest, usage x509.ExtKeyUsage, leafKey crypto.Signer, validity time.Duration) ([]byte, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	notAfter := now.Add(validity)
+	if notAfter.After(a.cert.NotAfter) {
+		notAfter = a.cert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        csr.Subject,
+		NotBefore:      now.Add(-5 * time.Minute),
+		NotAfter:       notAfter,
+		KeyUsage:       keyUsage(leafKey),
+		ExtKeyUsage:    []x509.ExtKeyUsage{usage},
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		URIs:           csr.URIs,
+		EmailAddresses: csr.EmailAddresses,
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, a.cert, csr.PublicKey, a.key)
+}
+
+func newCSR(cn string, sans []string, key crypto.Signer) ([]byte, *x509.CertificateRequest, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: cn},
+	}
+	if err := subjectAltNames(tmpl, sans); err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return der, csr, nil
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	b := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	return ioutil.WriteFile(path, b, perm)
+}
+
+func writeKey(path string, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "PRIVATE KEY", der, 0600)
+}
@@ -0,0 +1,143 @@
+// Command jwtgen creates the signing key of bearer tokens and issues tokens
+// accepted by the greet server.
+//
+// Create the ES256 signing key and the JSON Web Key Set the server verifies
+// tokens with:
+//
+//	jwtgen -gen-key -out ssl
+//
+// Issue a token:
+//
+//	jwtgen -sub alice -roles admin -ttl 1h > ssl/alice.token
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+)
+
+const (
+	signingKeyFile = "jwt.key"
+	jwksFile       = "jwks.json"
+)
+
+func main() {
+	genKey := flag.Bool("gen-key", false, "generate the signing key and key set instead of a token")
+	out := flag.String("out", "ssl", "directory to write the signing key and key set into (used with -gen-key)")
+	keyFile := flag.String("key", filepath.Join("ssl", signingKeyFile), "signing key to issue tokens with")
+	iss := flag.String("iss", "greet-issuer", "issuer (iss) claim")
+	aud := flag.String("aud", "greet-service", "audience (aud) claim")
+	sub := flag.String("sub", "", "subject (sub) claim")
+	roles := flag.String("roles", "", "comma separated roles claim")
+	scope := flag.String("scope", "", "scope claim")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	if *genKey {
+		if err := generateKeys(*out); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *sub == "" {
+		log.Fatal("-sub is required")
+	}
+	token, err := issueToken(*keyFile, &auth.Claims{
+		Claims: jwt.Claims{
+			Issuer:   *iss,
+			Audience: jwt.Audience{*aud},
+			Subject:  *sub,
+		},
+		Roles: splitList(*roles),
+		Scope: *scope,
+	}, *ttl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
+
+func generateKeys(out string) error {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return err
+	}
+	jwk := jose.JSONWebKey{
+		Key:       priv,
+		KeyID:     hex.EncodeToString(kid),
+		Algorithm: string(jose.ES256),
+		Use:       "sig",
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(out, signingKeyFile), jwk, 0600); err != nil {
+		return err
+	}
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}
+	if err := writeJSON(filepath.Join(out, jwksFile), jwks, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v and %v (kid=%v)\n", filepath.Join(out, signingKeyFile), filepath.Join(out, jwksFile), jwk.KeyID)
+	return nil
+}
+
+func issueToken(keyFile string, claims *auth.Claims, ttl time.Duration) (string, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	var jwk jose.JSONWebKey
+	if err := json.Unmarshal(b, &jwk); err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(jwk.Algorithm), Key: jwk},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.Expiry = jwt.NewNumericDate(now.Add(ttl))
+	return jwt.Signed(signer).Claims(claims).CompactSerialize()
+}
+
+func writeJSON(path string, v interface{}, perm os.FileMode) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), perm)
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
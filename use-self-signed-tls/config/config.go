@@ -0,0 +1,148 @@
+// Package config lets the flags of a command be set from environment
+// variables and a YAML config file as well as from the command line.
+//
+// Every flag can be set, in increasing order of precedence, by
+//
+//   - its default value,
+//   - the config file, keyed by the flag name, e.g. "drain-timeout: 10s",
+//   - the environment variable named by the prefix and the flag name in upper
+//     case with dashes replaced by underscores, e.g. GREET_SERVER_DRAIN_TIMEOUT,
+//   - the command line.
+//
+// List values such as listen addresses are comma separated strings, or YAML
+// sequences in the config file.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source is where the value of a flag came from.
+type Source string
+
+// Sources of flag values.
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Config is the effective configuration of a command.
+type Config struct {
+	fs      *flag.FlagSet
+	file    string
+	sources map[string]Source
+}
+
+// Load sets the flags of the parsed fs that were not given on the command
+// line from the environment variables prefixed with envPrefix and from file.
+// An empty file is read from the envPrefix_CONFIG environment variable, if
+// set; otherwise no config file is used.
+func Load(fs *flag.FlagSet, envPrefix, file string) (*Config, error) {
+	c := &Config{fs: fs, file: file, sources: make(map[string]Source)}
+	fs.VisitAll(func(f *flag.Flag) {
+		c.sources[f.Name] = SourceDefault
+	})
+	fs.Visit(func(f *flag.Flag) {
+		c.sources[f.Name] = SourceFlag
+	})
+	if c.file == "" {
+		c.file = os.Getenv(EnvName(envPrefix, "config"))
+	}
+	if c.file != "" {
+		values, err := readFile(c.file)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(values, SourceFile); err != nil {
+			return nil, fmt.Errorf("%s:%v", c.file, err)
+		}
+	}
+	env := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(EnvName(envPrefix, f.Name)); ok {
+			env[f.Name] = v
+		}
+	})
+	if err := c.apply(env, SourceEnv); err != nil {
+		return nil, fmt.Errorf("environment:%v", err)
+	}
+	return c, nil
+}
+
+// EnvName returns the environment variable setting the flag name.
+func EnvName(prefix, name string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func (c *Config) apply(values map[string]string, src Source) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		if c.sources[name] == SourceFlag {
+			continue
+		}
+		if err := c.fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid value %q for %s:%v", values[name], name, err)
+		}
+		c.sources[name] = src
+	}
+	return nil
+}
+
+func readFile(file string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s:%v", file, err)
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case []interface{}:
+			l := make([]string, len(v))
+			for i, e := range v {
+				l[i] = fmt.Sprint(e)
+			}
+			values[k] = strings.Join(l, ",")
+		case nil:
+			values[k] = ""
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// Log logs the effective value and source of every flag on one line.
+func (c *Config) Log(logger *slog.Logger) {
+	var attrs []any
+	if c.file != "" {
+		attrs = append(attrs, slog.String("config_file", c.file))
+	}
+	c.fs.VisitAll(func(f *flag.Flag) {
+		attrs = append(attrs, slog.Group(f.Name,
+			slog.String("value", f.Value.String()),
+			slog.String("source", string(c.sources[f.Name])),
+		))
+	})
+	logger.Info("Effective config", attrs...)
+}
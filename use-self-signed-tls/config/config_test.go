@@ -0,0 +1,168 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPrefix = "TEST_SERVER"
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.String("listen", "tls://:50051", "")
+	fs.Duration("drain-timeout", 5*time.Second, "")
+	fs.Bool("reflection", false, "")
+	return fs
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		file string
+		env  map[string]string
+		// The value and source of drain-timeout.
+		want    string
+		wantSrc Source
+	}{
+		{"default", nil, "", nil, "5s", SourceDefault},
+		{"file over default", nil, "drain-timeout: 10s", nil, "10s", SourceFile},
+		{"env over default", nil, "", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": "20s"}, "20s", SourceEnv},
+		{"env over file", nil, "drain-timeout: 10s", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": "20s"}, "20s", SourceEnv},
+		{"flag over default", []string{"-drain-timeout", "30s"}, "", nil, "30s", SourceFlag},
+		{"flag over file", []string{"-drain-timeout", "30s"}, "drain-timeout: 10s", nil, "30s", SourceFlag},
+		{"flag over env", []string{"-drain-timeout", "30s"}, "", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": "20s"}, "30s", SourceFlag},
+		{"flag over file and env", []string{"-drain-timeout", "30s"}, "drain-timeout: 10s", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": "20s"}, "30s", SourceFlag},
+		{"flag set to its default", []string{"-drain-timeout", "5s"}, "drain-timeout: 10s", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": "20s"}, "5s", SourceFlag},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			fs := newFlagSet()
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			var file string
+			if tt.file != "" {
+				file = writeConfig(t, tt.file)
+			}
+			c, err := Load(fs, testPrefix, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fs.Lookup("drain-timeout").Value.String(); got != tt.want {
+				t.Errorf("drain-timeout = %s, want %s", got, tt.want)
+			}
+			if got := c.sources["drain-timeout"]; got != tt.wantSrc {
+				t.Errorf("drain-timeout source = %s, want %s", got, tt.wantSrc)
+			}
+			// Settings of other flags are independent.
+			if got := c.sources["reflection"]; got != SourceDefault {
+				t.Errorf("reflection source = %s, want default", got)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		listen  string
+		reflect string
+	}{
+		{"sequence", "listen:\n  - tls://:50051\n  - unix:///tmp/greet.sock\n", "tls://:50051,unix:///tmp/greet.sock", "false"},
+		{"comma separated", "listen: tls://:50051,tcp://127.0.0.1:50052\n", "tls://:50051,tcp://127.0.0.1:50052", "false"},
+		{"bool", "reflection: true\n", "tls://:50051", "true"},
+		{"null", "listen:\nreflection: true\n", "", "true"},
+		{"empty file", "", "tls://:50051", "false"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newFlagSet()
+			if _, err := Load(fs, testPrefix, writeConfig(t, tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			if got := fs.Lookup("listen").Value.String(); got != tt.listen {
+				t.Errorf("listen = %q, want %q", got, tt.listen)
+			}
+			if got := fs.Lookup("reflection").Value.String(); got != tt.reflect {
+				t.Errorf("reflection = %s, want %s", got, tt.reflect)
+			}
+		})
+	}
+}
+
+// TestLoadFileFromEnv checks that the config file is read from
+// <prefix>_CONFIG when none is given.
+func TestLoadFileFromEnv(t *testing.T) {
+	t.Setenv("TEST_SERVER_CONFIG", writeConfig(t, "drain-timeout: 10s"))
+	fs := newFlagSet()
+	c, err := Load(fs, testPrefix, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("drain-timeout").Value.String(); got != "10s" || c.sources["drain-timeout"] != SourceFile {
+		t.Errorf("drain-timeout = %s from %s, want 10s from file", got, c.sources["drain-timeout"])
+	}
+
+	// A file given explicitly wins over the environment.
+	fs = newFlagSet()
+	if _, err := Load(fs, testPrefix, writeConfig(t, "drain-timeout: 15s")); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("drain-timeout").Value.String(); got != "15s" {
+		t.Errorf("drain-timeout = %s, want 15s of the given file", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		env     map[string]string
+	}{
+		{"unknown file setting", "drain_timeout: 10s", nil},
+		{"invalid file value", "drain-timeout: soon", nil},
+		{"invalid YAML", "drain-timeout: [", nil},
+		{"not a mapping", "- drain-timeout", nil},
+		{"invalid env value", "", map[string]string{"TEST_SERVER_REFLECTION": "maybe"}},
+		{"empty env value", "drain-timeout: 10s", map[string]string{"TEST_SERVER_DRAIN_TIMEOUT": ""}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var file string
+			if tt.content != "" {
+				file = writeConfig(t, tt.content)
+			}
+			if _, err := Load(newFlagSet(), testPrefix, file); err == nil {
+				t.Error("Load succeeded, want error")
+			}
+		})
+	}
+
+	if _, err := Load(newFlagSet(), testPrefix, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file succeeded, want error")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got, want := EnvName("GREET_SERVER", "grpc-web-addr"), "GREET_SERVER_GRPC_WEB_ADDR"; got != want {
+		t.Errorf("EnvName = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Listener is an address to accept connections on.
+type Listener struct {
+	// Network is "tcp" or "unix".
+	Network string
+	// Address is host:port for tcp and the socket path for unix.
+	Address string
+	// TLS reports whether connections are secured with TLS.
+	TLS bool
+}
+
+// ParseListeners parses a comma separated list of listen addresses:
+//
+//	tls://host:port        TLS over TCP
+//	tcp://host:port        plaintext TCP
+//	unix:///path           plaintext Unix socket
+//	tls+unix:///path       TLS over a Unix socket
+//	host:port              TCP, with TLS when defaultTLS is set
+//
+// allowTLS rejects TLS listeners for servers without a certificate.
+func ParseListeners(s string, defaultTLS, allowTLS bool) ([]Listener, error) {
+	var ls []Listener
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		l, err := parseListener(addr, defaultTLS)
+		if err != nil {
+			return nil, err
+		}
+		if l.TLS && !allowTLS {
+			return nil, fmt.Errorf("listen address %q: TLS is not supported by this server", addr)
+		}
+		ls = append(ls, l)
+	}
+	if len(ls) == 0 {
+		return nil, errors.New("no listen address")
+	}
+	seen := make(map[string]bool)
+	for _, l := range ls {
+		key := l.Network + ":" + l.Address
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate listen address %q", l)
+		}
+		seen[key] = true
+	}
+	return ls, nil
+}
+
+func parseListener(addr string, defaultTLS bool) (Listener, error) {
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if !ok {
+		scheme, rest = "", addr
+	}
+	var l Listener
+	switch scheme {
+	case "":
+		l = Listener{Network: "tcp", Address: rest, TLS: defaultTLS}
+	case "tcp":
+		l = Listener{Network: "tcp", Address: rest}
+	case "tls":
+		l = Listener{Network: "tcp", Address: rest, TLS: true}
+	case "unix":
+		l = Listener{Network: "unix", Address: rest}
+	case "tls+unix":
+		l = Listener{Network: "unix", Address: rest, TLS: true}
+	default:
+		return Listener{}, fmt.Errorf("listen address %q: unknown scheme %q", addr, scheme)
+	}
+	if l.Network == "tcp" {
+		if _, _, err := net.SplitHostPort(l.Address); err != nil {
+			return Listener{}, fmt.Errorf("listen address %q:%v", addr, err)
+		}
+	} else if !strings.HasPrefix(l.Address, "/") {
+		return Listener{}, fmt.Errorf("listen address %q: socket path must be absolute", addr)
+	}
+	return l, nil
+}
+
+// String returns l in the syntax of ParseListeners.
+func (l Listener) String() string {
+	scheme := l.Network
+	if l.TLS {
+		scheme = map[string]string{"tcp": "tls", "unix": "tls+unix"}[l.Network]
+	}
+	return scheme + "://" + l.Address
+}
+
+// Listen starts listening on l. A Unix socket file left over by a previous
+// run is removed first.
+func (l Listener) Listen() (net.Listener, error) {
+	if l.Network == "unix" {
+		if fi, err := os.Stat(l.Address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if c, err := net.Dial("unix", l.Address); err == nil {
+				c.Close()
+				return nil, fmt.Errorf("listen %v: socket in use", l)
+			}
+			os.Remove(l.Address)
+		}
+	}
+	return net.Listen(l.Network, l.Address)
+}
+
+// DialTarget returns the gRPC target reaching l from the same host.
+func (l Listener) DialTarget() string {
+	if l.Network == "unix" {
+		return "unix://" + l.Address
+	}
+	host, port, _ := net.SplitHostPort(l.Address)
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
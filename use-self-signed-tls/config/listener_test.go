@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListeners(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		s          string
+		defaultTLS bool
+		want       []Listener
+	}{
+		{"tls", "tls://:50051", false, []Listener{{"tcp", ":50051", true}}},
+		{"tcp", "tcp://127.0.0.1:50052", true, []Listener{{"tcp", "127.0.0.1:50052", false}}},
+		{"unix", "unix:///tmp/greet.sock", true, []Listener{{"unix", "/tmp/greet.sock", false}}},
+		{"tls+unix", "tls+unix:///tmp/greet.sock", false, []Listener{{"unix", "/tmp/greet.sock", true}}},
+		{"bare with TLS by default", "localhost:50051", true, []Listener{{"tcp", "localhost:50051", true}}},
+		{"bare without TLS by default", "localhost:50051", false, []Listener{{"tcp", "localhost:50051", false}}},
+		{"IPv6", "tls://[::1]:50051", false, []Listener{{"tcp", "[::1]:50051", true}}},
+		{"list", " tls://:50051, tcp://127.0.0.1:50052,,unix:///tmp/greet.sock ", false, []Listener{
+			{"tcp", ":50051", true},
+			{"tcp", "127.0.0.1:50052", false},
+			{"unix", "/tmp/greet.sock", false},
+		}},
+		{"TLS and plaintext", "tls://:50051,tcp://:50052", false, []Listener{
+			{"tcp", ":50051", true},
+			{"tcp", ":50052", false},
+		}},
+		{"empty", "", false, nil},
+		{"blank entries only", " , ", false, nil},
+		{"unknown scheme", "http://:8080", false, nil},
+		{"missing port", "tls://localhost", false, nil},
+		{"bare without port", "localhost", false, nil},
+		{"relative socket path", "unix://greet.sock", false, nil},
+		{"duplicate", "tls://:50051,:50051", true, nil},
+		{"duplicate socket", "unix:///tmp/greet.sock,tls+unix:///tmp/greet.sock", false, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseListeners(tt.s, tt.defaultTLS, true)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("ParseListeners(%q) = %v, want error", tt.s, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseListeners(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseListenersWithoutTLS(t *testing.T) {
+	for _, s := range []string{"tls://:50051", "tls+unix:///tmp/greet.sock", ":50051"} {
+		if _, err := ParseListeners(s, true, false); err == nil {
+			t.Errorf("ParseListeners(%q) without TLS succeeded, want error", s)
+		}
+	}
+	ls, err := ParseListeners("tcp://:50051,unix:///tmp/greet.sock,:50052", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 3 {
+		t.Errorf("got %d listeners, want 3", len(ls))
+	}
+}
+
+func TestListenerString(t *testing.T) {
+	for _, s := range []string{"tls://:50051", "tcp://127.0.0.1:50052", "unix:///tmp/greet.sock", "tls+unix:///tmp/greet.sock"} {
+		ls, err := ParseListeners(s, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ls[0].String(); got != s {
+			t.Errorf("String of %q = %q", s, got)
+		}
+	}
+}
+
+func TestDialTarget(t *testing.T) {
+	for _, tt := range []struct {
+		l    Listener
+		want string
+	}{
+		{Listener{"tcp", ":50051", true}, "localhost:50051"},
+		{Listener{"tcp", "0.0.0.0:50051", false}, "localhost:50051"},
+		{Listener{"tcp", "[::]:50051", true}, "localhost:50051"},
+		{Listener{"tcp", "127.0.0.1:50052", false}, "127.0.0.1:50052"},
+		{Listener{"tcp", "[::1]:50051", true}, "[::1]:50051"},
+		{Listener{"tcp", "greet.example.com:443", true}, "greet.example.com:443"},
+		{Listener{"unix", "/tmp/greet.sock", false}, "unix:///tmp/greet.sock"},
+		{Listener{"unix", "/tmp/greet.sock", true}, "unix:///tmp/greet.sock"},
+	} {
+		if got := tt.l.DialTarget(); got != tt.want {
+			t.Errorf("DialTarget of %v = %q, want %q", tt.l, got, tt.want)
+		}
+	}
+}
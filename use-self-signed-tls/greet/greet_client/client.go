@@ -1,197 +1,179 @@
+// Command greet_client calls GreetService, one RPC per subcommand:
+//
+//	greet_client [flags] greet -first-name Alice
+//	greet_client -output json many -count 3 -interval 200ms
+//	printf 'Alice\nBob Smith\n' | greet_client long
+//	greet_client everyone Alice "Bob Smith"
+//	greet_client -timeout 2s deadline
+//	greet_client health -service greet.GreetService
+//
+// Without a command, greet is called.
 package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"log"
-	"time"
+	"os"
 
-	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+	"github.com/hrfmmr/grpc-go-sandbox/config"
+	"github.com/hrfmmr/grpc-go-sandbox/lb"
+	"github.com/hrfmmr/grpc-go-sandbox/serviceconfig"
+	"github.com/hrfmmr/grpc-go-sandbox/telemetry"
+	"github.com/hrfmmr/grpc-go-sandbox/validate"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	fmt.Println("Hello, I'm a client")
-
-	certFile := "ssl/ca.crt"
-	creds, err := credentials.NewClientTLSFromFile(certFile, "")
-	if err != nil {
+	configFile := flag.String("config", "", "YAML config file setting any of these flags by name (or GREET_CLIENT_CONFIG)")
+	target := flag.String("target", "localhost:50051", "server address: host:port, unix:///path, dns:///host:port, static:///host:port,host:port;weight=N or file:///path/to/endpoints.json")
+	lbPolicy := flag.String("lb-policy", "", "load balancing policy across the addresses of -target: pick_first, round_robin or "+lb.WeightedName+" (empty for the service config's or pick_first)")
+	serverName := flag.String("server-name", "", "server name to verify the TLS certificate with (default the -target host; needed for targets with several backends)")
+	plaintext := flag.Bool("plaintext", false, "connect without TLS, e.g. to a tcp:// or unix:// listener of the server")
+	caFile := flag.String("ca", "ssl/ca.crt", "CA certificate to verify the server with")
+	certFile := flag.String("cert", "", "client certificate file for mutual TLS")
+	keyFile := flag.String("key", "", "client private key file for mutual TLS")
+	tokenFile := flag.String("token-file", "", "file holding the bearer token to send with every RPC")
+	timeout := flag.Duration("timeout", 0, "deadline of each RPC (0 for none)")
+	serviceConfig := flag.String("service-config", "", "gRPC service config JSON with per-method retry and hedging policies, timeouts and waitForReady")
+	serviceConfigFile := flag.String("service-config-file", "", "file holding the service config (instead of -service-config)")
+	output := flag.String("output", outputText, "output format of responses: text or json")
+	traceExporter := flag.String("trace-exporter", telemetry.TraceExporterNone, "trace exporter: none, stdout or otlp")
+	otlpEndpoint := flag.String("otlp-endpoint", "localhost:4317", "OTLP/gRPC collector endpoint (used with -trace-exporter otlp)")
+	flag.Usage = usage
+	flag.Parse()
+	if _, err := config.Load(flag.CommandLine, "GREET_CLIENT", *configFile); err != nil {
 		log.Fatal(err)
 	}
-	opts := grpc.WithTransportCredentials(creds)
-	cc, err := grpc.Dial("localhost:50051", opts)
-	if err != nil {
-		log.Fatalf("could not connect:%v", err)
-	}
-	defer cc.Close()
-	c := greetpb.NewGreetServiceClient(cc)
-	doUnary(c)
-	// doServerStreaming(c)
-	// doClientStreaming(c)
-	// doBiDiStreaming(c)
-	// doUnaryWithDeadline(c, "Alice", 5*time.Second) // should complete
-	// doUnaryWithDeadline(c, "Bob", 1*time.Second)   // should timeout
-}
 
-func doUnary(c greetpb.GreetServiceClient) {
-	fmt.Println("Starting to do a Unary RPC...")
-	req := &greetpb.GreetRequest{
-		Greeting: &greetpb.Greeting{
-			FirstName: "John",
-			LastName:  "Doe",
-		},
-	}
-	rsp, err := c.Greet(context.Background(), req)
+	name := "greet"
+	if flag.NArg() > 0 {
+		name = flag.Arg(0)
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	out, err := newPrinter(os.Stdout, *output)
 	if err != nil {
-		log.Fatalf("error while calling Greet RPC:%v", err)
+		log.Fatal(err)
 	}
-	log.Printf("Response from Greet:%v", rsp.Result)
-}
 
-func doServerStreaming(c greetpb.GreetServiceClient) {
-	fmt.Println("Starting to do a Server Streaming RPC...")
-	req := &greetpb.GreetManyTimesRequest{
-		Greeting: &greetpb.Greeting{
-			FirstName: "John",
-			LastName:  "Doe",
-		},
-	}
-	resStream, err := c.GreetManyTimes(context.Background(), req)
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		ServiceName:  "greet-client",
+		Exporter:     *traceExporter,
+		OTLPEndpoint: *otlpEndpoint,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	for {
-		msg, err := resStream.Recv()
-		if err == io.EOF {
-			break
+
+	creds := insecure.NewCredentials()
+	if !*plaintext {
+		creds, err = newClientCreds(*caFile, *certFile, *keyFile, *serverName)
+		if err != nil {
+			log.Fatal(err)
 		}
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		telemetry.ClientStatsHandler(),
+	}
+	if *tokenFile != "" {
+		token, err := auth.BearerTokenFromFile(*tokenFile)
 		if err != nil {
 			log.Fatal(err)
 		}
-		log.Printf("Response:%v", msg.Result)
+		opts = append(opts, grpc.WithPerRPCCredentials(token))
+	}
+	var sc *serviceconfig.Config
+	switch {
+	case *serviceConfig != "" && *serviceConfigFile != "":
+		log.Fatal("-service-config and -service-config-file are mutually exclusive")
+	case *serviceConfig != "":
+		sc, err = serviceconfig.Parse(*serviceConfig)
+	case *serviceConfigFile != "":
+		sc, err = serviceconfig.ReadFile(*serviceConfigFile)
 	}
-}
-
-func doClientStreaming(c greetpb.GreetServiceClient) {
-	reqs := []*greetpb.LongGreetRequest{
-		&greetpb.LongGreetRequest{
-			Greeting: &greetpb.Greeting{
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-		},
-		&greetpb.LongGreetRequest{
-			Greeting: &greetpb.Greeting{
-				FirstName: "Alice",
-				LastName:  "",
-			},
-		},
-	}
-	stream, err := c.LongGreet(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, req := range reqs {
-		fmt.Printf("Sending req:%+v\n", req)
-		stream.Send(req)
-		time.Sleep(1000 * time.Millisecond)
+	if *lbPolicy != "" {
+		if sc == nil {
+			sc, _ = serviceconfig.Parse("{}")
+		}
+		if err := sc.SetLoadBalancingPolicy(*lbPolicy); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if sc != nil {
+		opts = append(opts, sc.DialOptions()...)
 	}
-	rsp, err := stream.CloseAndRecv()
+	cc, err := grpc.Dial(*target, opts...)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("could not connect:%v", err)
 	}
-	fmt.Printf("LongGreet rsp %+v\n", rsp)
-}
 
-func doBiDiStreaming(c greetpb.GreetServiceClient) {
-	fmt.Println("Starting BiDi streaming RPC...")
-	stream, err := c.GreetEveryone(context.Background())
-	if err != nil {
-		log.Fatal(err)
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
 	}
-	reqs := []*greetpb.GreetEveryoneRequest{
-		&greetpb.GreetEveryoneRequest{
-			Greeting: &greetpb.Greeting{
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-		},
-		&greetpb.GreetEveryoneRequest{
-			Greeting: &greetpb.Greeting{
-				FirstName: "Taro",
-				LastName:  "Yamada",
-			},
-		},
+	var args []string
+	if flag.NArg() > 0 {
+		args = flag.Args()[1:]
 	}
+	err = cmd.run(ctx, cc, out, args)
+	cancel()
+	cc.Close()
+	shutdownTracing(context.Background())
+	if err != nil {
+		log.Printf("%s failed:%v\n", cmd.name, validate.FormatError(err))
+		os.Exit(1)
+	}
+}
 
-	waitc := make(chan struct{})
-
-	// send a bunch of messages
-	go func() {
-		for _, req := range reqs {
-			fmt.Printf("Sending req = %+v\n", req)
-			if err := stream.Send(req); err != nil {
-				log.Fatal(err)
-				close(waitc)
-			}
-			time.Sleep(500 * time.Millisecond)
-		}
-		if err := stream.CloseSend(); err != nil {
-			log.Fatal(err)
-			close(waitc)
-		}
-	}()
-
-	// recv a bunch of messages
-	go func() {
-		for {
-			rsp, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatal(err)
-				break
-			}
-			fmt.Printf("Received rsp = %+v\n", rsp)
-		}
-		close(waitc)
-	}()
-	fmt.Println("👀 waiting stream finished...")
-	<-waitc
-	fmt.Println("✔Done")
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [flags] [command [command flags] [args]]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun %s <command> -h for the flags of a command.\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
 }
 
-func doUnaryWithDeadline(c greetpb.GreetServiceClient, firstName string, timeout time.Duration) {
-	log.Printf("👉Starting unary with deadline RPC... name:%v\n", firstName)
-	req := &greetpb.GreetWithDeadlineRequest{
-		Greeting: &greetpb.Greeting{
-			FirstName: firstName,
-			LastName:  "Doe",
-		},
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer func() {
-		log.Printf("cancel name:%+v\n", firstName)
-		cancel()
-	}()
-	rsp, err := c.GreetWithDeadline(ctx, req)
+// newClientCreds builds the client transport credentials.
+// When certFile and keyFile are given, the pair is presented to the server
+// as the client certificate (mutual TLS). A non-empty serverName overrides
+// the name the server certificate is verified with.
+func newClientCreds(caFile, certFile, keyFile, serverName string) (credentials.TransportCredentials, error) {
+	b, err := ioutil.ReadFile(caFile)
 	if err != nil {
-		statusErr, ok := status.FromError(err)
-		if ok {
-			if statusErr.Code() == codes.DeadlineExceeded {
-				log.Printf("⏰Timeout!! name:%v\n", firstName)
-			} else {
-				log.Printf("❗Unexpected error:%v\n", statusErr)
-			}
-		} else {
-			log.Fatal(err)
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	cfg := &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
 		}
-		return
+		cfg.Certificates = []tls.Certificate{cert}
 	}
-	log.Printf("✅rsp = %+v\n", rsp)
+	return credentials.NewTLS(cfg), nil
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// command is a subcommand calling one RPC. args are the arguments following
+// the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error
+}
+
+var commands = []command{
+	{"greet", "call Greet (unary)", doGreet},
+	{"many", "call GreetManyTimes (server streaming)", doGreetManyTimes},
+	{"long", "call LongGreet (client streaming) with names from args or stdin", doLongGreet},
+	{"everyone", "call GreetEveryone (bidirectional streaming) with names from args or stdin", doGreetEveryone},
+	{"deadline", "call GreetWithDeadline, which takes 4s to respond", doGreetWithDeadline},
+	{"health", "check the server health", doHealthCheck},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// nameFlags registers the -first-name and -last-name flags on fs.
+func nameFlags(fs *flag.FlagSet) func() *greetpb.Greeting {
+	firstName := fs.String("first-name", "John", "first name to greet")
+	lastName := fs.String("last-name", "Doe", "last name to greet")
+	return func() *greetpb.Greeting {
+		return &greetpb.Greeting{
+			FirstName: *firstName,
+			LastName:  *lastName,
+		}
+	}
+}
+
+// streamFlags parses the arguments of the commands streaming names.
+func streamFlags(name string, args []string) []string {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [name ...]\n\n"+
+			"Each name is \"First [Last]\". Without names, one name per line is read\n"+
+			"from stdin and sent as soon as it is read.\n", name)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	return fs.Args()
+}
+
+// parseName splits "First Last" into a Greeting. Everything after the first
+// word is the last name.
+func parseName(s string) *greetpb.Greeting {
+	first, last, _ := strings.Cut(strings.TrimSpace(s), " ")
+	return &greetpb.Greeting{
+		FirstName: first,
+		LastName:  strings.TrimSpace(last),
+	}
+}
+
+// sendNames calls send with the names in args, or else with the non-empty
+// lines of stdin as soon as each is read. An error of send ends the input
+// without error: the stream then fails with the RPC status.
+func sendNames(args []string, send func(*greetpb.Greeting) error) error {
+	if len(args) > 0 {
+		for _, a := range args {
+			if err := send(parseName(a)); err != nil {
+				return nil
+			}
+		}
+		return nil
+	}
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		if err := send(parseName(sc.Text())); err != nil {
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+func doGreet(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	fs := flag.NewFlagSet("greet", flag.ExitOnError)
+	greeting := nameFlags(fs)
+	count := fs.Int("count", 1, "number of calls; with more than one, the calls per backend are logged at the end")
+	interval := fs.Duration("interval", 0, "interval between calls")
+	fs.Parse(args)
+
+	c := greetpb.NewGreetServiceClient(cc)
+	backends := newBackendCounter()
+	defer backends.log()
+	for i := 0; i < *count; i++ {
+		if i > 0 && *interval > 0 {
+			time.Sleep(*interval)
+		}
+		var p peer.Peer
+		rsp, err := c.Greet(ctx, &greetpb.GreetRequest{
+			Greeting: greeting(),
+		}, grpc.Peer(&p))
+		if err != nil {
+			return err
+		}
+		if *count > 1 {
+			backends.add(p.Addr)
+		}
+		if err := out.print(rsp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backendCounter counts the calls served by each backend to show how they
+// are balanced.
+type backendCounter struct {
+	order  []string
+	counts map[string]int
+}
+
+func newBackendCounter() *backendCounter {
+	return &backendCounter{counts: make(map[string]int)}
+}
+
+func (c *backendCounter) add(addr net.Addr) {
+	if addr == nil {
+		return
+	}
+	a := addr.String()
+	if _, ok := c.counts[a]; !ok {
+		c.order = append(c.order, a)
+	}
+	c.counts[a]++
+}
+
+func (c *backendCounter) log() {
+	for _, a := range c.order {
+		log.Printf("backend:%v calls:%d\n", a, c.counts[a])
+	}
+}
+
+func doGreetManyTimes(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	fs := flag.NewFlagSet("many", flag.ExitOnError)
+	greeting := nameFlags(fs)
+	count := fs.Int("count", 0, "number of responses (0 for the server default of 10)")
+	interval := fs.Duration("interval", 0, "interval between responses (0 for the server default of 1s)")
+	jitter := fs.Duration("jitter", 0, "upper bound of a random delay added to each interval")
+	fs.Parse(args)
+
+	stream, err := greetpb.NewGreetServiceClient(cc).GreetManyTimes(ctx, &greetpb.GreetManyTimesRequest{
+		Greeting:   greeting(),
+		Count:      int32(*count),
+		IntervalMs: int32(*interval / time.Millisecond),
+		JitterMs:   int32(*jitter / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		rsp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := out.print(rsp); err != nil {
+			return err
+		}
+	}
+}
+
+func doLongGreet(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	args = streamFlags("long", args)
+	stream, err := greetpb.NewGreetServiceClient(cc).LongGreet(ctx)
+	if err != nil {
+		return err
+	}
+	if err := sendNames(args, func(g *greetpb.Greeting) error {
+		return stream.Send(&greetpb.LongGreetRequest{Greeting: g})
+	}); err != nil {
+		return err
+	}
+	rsp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return out.print(rsp)
+}
+
+func doGreetEveryone(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	args = streamFlags("everyone", args)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := greetpb.NewGreetServiceClient(cc).GreetEveryone(ctx)
+	if err != nil {
+		return err
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		err := sendNames(args, func(g *greetpb.Greeting) error {
+			return stream.Send(&greetpb.GreetEveryoneRequest{Greeting: g})
+		})
+		if err != nil {
+			sendErr <- err
+			cancel()
+			return
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	for {
+		rsp, err := stream.Recv()
+		if err == io.EOF {
+			return <-sendErr
+		}
+		if err != nil {
+			select {
+			case serr := <-sendErr:
+				if serr != nil {
+					return serr
+				}
+			default:
+			}
+			return err
+		}
+		if err := out.print(rsp); err != nil {
+			return err
+		}
+	}
+}
+
+func doGreetWithDeadline(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	fs := flag.NewFlagSet("deadline", flag.ExitOnError)
+	greeting := nameFlags(fs)
+	timeout := fs.Duration("timeout", 5*time.Second, "deadline of the call; below 4s it fails with DeadlineExceeded")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	rsp, err := greetpb.NewGreetServiceClient(cc).GreetWithDeadline(ctx, &greetpb.GreetWithDeadlineRequest{
+		Greeting: greeting(),
+	})
+	if err != nil {
+		return err
+	}
+	return out.print(rsp)
+}
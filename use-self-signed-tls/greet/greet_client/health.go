@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// doHealthCheck probes the standard gRPC health service. It fails unless the
+// service is SERVING, so that the process exit code reflects the health.
+func doHealthCheck(ctx context.Context, cc *grpc.ClientConn, out *printer, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	service := fs.String("service", "", "service to check (empty for the overall server health)")
+	timeout := fs.Duration("timeout", 5*time.Second, "health check timeout")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	rsp, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: *service,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("service:%q status:%v\n", *service, rsp.GetStatus())
+	if rsp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("service:%q is %v", *service, rsp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Output formats of responses.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// printer writes responses to w, one per line: the result in text format,
+// or the whole message as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+	m    jsonpb.Marshaler
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case outputText:
+		return &printer{w: w}, nil
+	case outputJSON:
+		return &printer{w: w, json: true}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+type resultMessage interface {
+	proto.Message
+	GetResult() string
+}
+
+func (p *printer) print(rsp resultMessage) error {
+	if !p.json {
+		_, err := fmt.Fprintln(p.w, rsp.GetResult())
+		return err
+	}
+	s, err := p.m.MarshalToString(rsp)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.w, s)
+	return err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// certReloader serves the server certificate for new TLS handshakes and
+// swaps it when the cert/key files change on disk or SIGHUP is received.
+// Connections already established keep using the certificate they were
+// handshaken with.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Serial returns the serial number of the active certificate in hex.
+func (r *certReloader) Serial() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return fmt.Sprintf("%X", r.cert.Leaf.SerialNumber)
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	log.Printf("🔐 Loaded certificate serial:%X subject:%v notAfter:%v\n", leaf.SerialNumber, leaf.Subject, leaf.NotAfter)
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) changed() bool {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		log.Printf("Failed to stat certificate files:%v\n", err)
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return modTime.After(r.modTime)
+}
+
+// Watch reloads the certificate whenever the files change, polling every
+// interval, or on SIGHUP. A zero interval disables polling. On failure the
+// previous certificate stays active. Watch returns when done is closed.
+func (r *certReloader) Watch(interval time.Duration, done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading certificate")
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+			log.Println("Certificate files changed, reloading certificate")
+		}
+		if err := r.reload(); err != nil {
+			log.Printf("Failed to reload certificate, keeping serial:%v err:%v\n", r.Serial(), err)
+		}
+	}
+}
+
+var certExpiryDesc = prometheus.NewDesc(
+	"tls_server_certificate_expiry_timestamp_seconds",
+	"Expiry of the active server certificate in Unix seconds.",
+	[]string{"serial", "subject"}, nil,
+)
+
+// Describe implements prometheus.Collector.
+func (r *certReloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- certExpiryDesc
+}
+
+// Collect implements prometheus.Collector reporting the active certificate.
+func (r *certReloader) Collect(ch chan<- prometheus.Metric) {
+	r.mu.RLock()
+	leaf := r.cert.Leaf
+	r.mu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(certExpiryDesc, prometheus.GaugeValue,
+		float64(leaf.NotAfter.Unix()), fmt.Sprintf("%X", leaf.SerialNumber), leaf.Subject.String())
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// testCA issues server certificates with chosen serials.
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// write issues a certificate for localhost with serial and writes it and
+// its key over certFile and keyFile, moving their mtime past any earlier
+// write.
+func (ca *testCA) write(t *testing.T, serial int64, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+// writeFile writes b to path and moves its mtime a second ahead, so that
+// writes within one tick of the filesystem clock still look changed.
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now()
+	if fi, err := os.Stat(path); err == nil && !fi.ModTime().Before(mtime) {
+		mtime = fi.ModTime()
+	}
+	mtime = mtime.Add(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reloadTest serves GreetService over TLS with the certificate of a
+// certReloader that a test swaps.
+type reloadTest struct {
+	ca                *testCA
+	certFile, keyFile string
+	reloader          *certReloader
+	server            *greettest.Server
+}
+
+func newReloadTest(t *testing.T) *reloadTest {
+	t.Helper()
+	dir := t.TempDir()
+	rt := &reloadTest{
+		ca:       newTestCA(t),
+		certFile: filepath.Join(dir, "server.crt"),
+		keyFile:  filepath.Join(dir, "server.pem"),
+	}
+	rt.ca.write(t, 100, rt.certFile, rt.keyFile)
+	r, err := newCertReloader(rt.certFile, rt.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := newServerCreds(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.reloader = r
+	rt.server = greettest.NewServer(t, grpc.Creds(creds))
+	return rt
+}
+
+// dial makes a new connection, so a new handshake, to the server.
+func (rt *reloadTest) dial(t *testing.T) greetpb.GreetServiceClient {
+	t.Helper()
+	return rt.server.Dial(t, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		ServerName: "localhost",
+		RootCAs:    rt.ca.pool,
+	})))
+}
+
+// peerSerial returns the serial of the certificate the server presented to
+// the connection of p.
+func peerSerial(t *testing.T, p *peer.Peer) int64 {
+	t.Helper()
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		t.Fatalf("no TLS peer certificate in %v", p.AuthInfo)
+	}
+	return info.State.PeerCertificates[0].SerialNumber.Int64()
+}
+
+// greetSerial greets over c and returns the serial the server presented.
+func greetSerial(t *testing.T, c greetpb.GreetServiceClient) int64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var p peer.Peer
+	if _, err := c.Greet(ctx, &greetpb.GreetRequest{Greeting: &greetpb.Greeting{FirstName: "John"}}, grpc.Peer(&p)); err != nil {
+		t.Fatal(err)
+	}
+	return peerSerial(t, &p)
+}
+
+// waitSerial waits for the reloader to serve serial, calling trigger until
+// it does.
+func waitSerial(t *testing.T, r *certReloader, serial string, trigger func()) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Serial() != serial {
+		if time.Now().After(deadline) {
+			t.Fatalf("serial = %s, want %s after reload", r.Serial(), serial)
+		}
+		trigger()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// checkRotation swaps the certificate for serial 200, which reload makes
+// active, and checks that new handshakes see it while a stream opened
+// before keeps working over the old one.
+func checkRotation(t *testing.T, rt *reloadTest, reload func()) {
+	t.Helper()
+	if got := greetSerial(t, rt.dial(t)); got != 100 {
+		t.Fatalf("serial before rotation = %d, want 100", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := rt.dial(t).GreetEveryone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange := func(name string) {
+		t.Helper()
+		if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: &greetpb.Greeting{FirstName: name}}); err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("open stream after rotation:%v", err)
+		}
+		if got, want := rsp.GetResult(), "Hello "+name+"! "; got != want {
+			t.Fatalf("GreetEveryone = %q, want %q", got, want)
+		}
+	}
+	exchange("Alice")
+
+	rt.ca.write(t, 200, rt.certFile, rt.keyFile)
+	waitSerial(t, rt.reloader, "C8", reload)
+	if got := greetSerial(t, rt.dial(t)); got != 200 {
+		t.Errorf("serial of a new handshake = %d, want 200", got)
+	}
+	exchange("Bob")
+	p, ok := peer.FromContext(stream.Context())
+	if !ok {
+		t.Fatal("no peer in the stream context")
+	}
+	if got := peerSerial(t, p); got != 100 {
+		t.Errorf("serial of the open stream = %d, want 100", got)
+	}
+}
+
+func TestCertReloadOnChange(t *testing.T) {
+	rt := newReloadTest(t)
+	done := make(chan struct{})
+	defer close(done)
+	go rt.reloader.Watch(10*time.Millisecond, done)
+	checkRotation(t, rt, func() {})
+}
+
+func TestCertReloadOnSIGHUP(t *testing.T) {
+	rt := newReloadTest(t)
+	// Keep SIGHUP from killing the test before Watch is notified of it.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	done := make(chan struct{})
+	defer close(done)
+	// Without polling, only SIGHUP reloads the certificate.
+	go rt.reloader.Watch(0, done)
+	checkRotation(t, rt, func() {
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	})
+}
+
+func TestCertReloadKeepsCertOnFailure(t *testing.T) {
+	rt := newReloadTest(t)
+	done := make(chan struct{})
+	defer close(done)
+	go rt.reloader.Watch(10*time.Millisecond, done)
+
+	// A key not matching the certificate fails to load.
+	other := filepath.Join(t.TempDir(), "other.pem")
+	rt.ca.write(t, 300, filepath.Join(t.TempDir(), "other.crt"), other)
+	b, err := ioutil.ReadFile(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, rt.keyFile, b)
+	time.Sleep(100 * time.Millisecond)
+	if got := greetSerial(t, rt.dial(t)); got != 100 {
+		t.Errorf("serial after a failed reload = %d, want 100", got)
+	}
+
+	rt.ca.write(t, 400, rt.certFile, rt.keyFile)
+	waitSerial(t, rt.reloader, "190", func() {})
+	if got := greetSerial(t, rt.dial(t)); got != 400 {
+		t.Errorf("serial after a fixed reload = %d, want 400", got)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// newServerCreds builds the server transport credentials serving the
+// certificate held by certs. When clientCAFile is given, clients are
+// required to present a certificate signed by one of the CAs in that bundle
+// (mutual TLS).
+func newServerCreds(certs *certReloader, clientCAFile string) (credentials.TransportCredentials, error) {
+	cfg, err := newServerTLSConfig(certs, clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// newServerTLSConfig is the tls.Config counterpart of newServerCreds for the
+// HTTP listeners.
+func newServerTLSConfig(certs *certReloader, clientCAFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"github.com/hrfmmr/grpc-go-sandbox/config"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newGateway returns the REST gateway of GreetService calling back into this
+// server through the listener l, like any other client so that
+// authentication applies. With mtls, it presents the client certificate
+// certFile/keyFile.
+func newGateway(l config.Listener, caFile, certFile, keyFile string, mtls bool) (http.Handler, error) {
+	creds := insecure.NewCredentials()
+	if l.TLS {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &tls.Config{
+			RootCAs: pool,
+		}
+		if l.Network == "unix" {
+			cfg.ServerName = "localhost"
+		}
+		if mtls {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	gw := web.NewGatewayMux()
+	err := greetpb.RegisterGreetServiceHandlerFromEndpoint(context.Background(), gw, l.DialTarget(),
+		[]grpc.DialOption{grpc.WithTransportCredentials(creds)})
+	if err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+
+	"github.com/hrfmmr/grpc-go-sandbox/config"
+	"google.golang.org/grpc/credentials"
+)
+
+// listen opens every listener in ls. Connections accepted on plaintext
+// listeners are marked so that the credentials returned by mixedCreds skip
+// the TLS handshake for them.
+func listen(ls []config.Listener) ([]net.Listener, error) {
+	var liss []net.Listener
+	for _, l := range ls {
+		lis, err := l.Listen()
+		if err != nil {
+			for _, lis := range liss {
+				lis.Close()
+			}
+			return nil, err
+		}
+		if !l.TLS {
+			lis = plaintextListener{lis}
+		}
+		liss = append(liss, lis)
+	}
+	return liss, nil
+}
+
+type plaintextListener struct {
+	net.Listener
+}
+
+func (l plaintextListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return plaintextConn{conn}, nil
+}
+
+type plaintextConn struct {
+	net.Conn
+}
+
+// isPlaintext reports whether lis was opened by listen for a plaintext
+// listener.
+func isPlaintext(lis net.Listener) bool {
+	_, ok := lis.(plaintextListener)
+	return ok
+}
+
+// mixedCreds returns credentials performing the TLS handshake of creds on
+// connections from TLS listeners only, so that one server can serve both.
+func mixedCreds(creds credentials.TransportCredentials) credentials.TransportCredentials {
+	return &mixed{TransportCredentials: creds}
+}
+
+type mixed struct {
+	credentials.TransportCredentials
+}
+
+func (c *mixed) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if _, ok := conn.(plaintextConn); ok {
+		return conn, plaintextAuthInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+	}
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+func (c *mixed) Clone() credentials.TransportCredentials {
+	return &mixed{TransportCredentials: c.TransportCredentials.Clone()}
+}
+
+type plaintextAuthInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (plaintextAuthInfo) AuthType() string {
+	return "insecure"
+}
@@ -2,121 +2,258 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"io"
+	"flag"
 	"log"
+	"log/slog"
 	"net"
-	"strconv"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+	"github.com/hrfmmr/grpc-go-sandbox/authz"
+	"github.com/hrfmmr/grpc-go-sandbox/config"
 	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetservice"
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	"github.com/hrfmmr/grpc-go-sandbox/telemetry"
+	"github.com/hrfmmr/grpc-go-sandbox/validate"
+	"github.com/hrfmmr/grpc-go-sandbox/web"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
-type server struct{}
+func main() {
+	configFile := flag.String("config", "", "YAML config file setting any of these flags by name (or GREET_SERVER_CONFIG)")
+	listenAddrs := flag.String("listen", "0.0.0.0:50051", "comma separated addresses to serve on: host:port or tls://host:port (TLS), tcp://host:port or unix:///path (plaintext), tls+unix:///path")
+	certFile := flag.String("cert", "ssl/server.crt", "server certificate file")
+	keyFile := flag.String("key", "ssl/server.pem", "server private key file")
+	mtls := flag.Bool("mtls", false, "require and verify client certificates; every listener must then use TLS")
+	clientCAFile := flag.String("client-ca", "ssl/ca.crt", "CA bundle to verify client certificates with (used with -mtls)")
+	jwksFile := flag.String("jwks", "", "JSON Web Key Set to verify bearer tokens with (empty to disable token authentication)")
+	jwtIssuer := flag.String("jwt-issuer", "greet-issuer", "expected issuer of bearer tokens")
+	jwtAudience := flag.String("jwt-audience", "greet-service", "expected audience of bearer tokens")
+	authzPolicy := flag.String("authz-policy", "", "authorization policy file (empty to disable authorization)")
+	authzReloadInterval := flag.Duration("authz-reload-interval", 10*time.Second, "interval to check the authorization policy for changes (0 to reload on SIGHUP only)")
+	enableReflection := flag.Bool("reflection", false, "register the server reflection service (for grpcurl and similar tools)")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "time to wait for in-flight RPCs to finish on shutdown before canceling them")
+	maxStreamCount := flag.Int("max-stream-count", 1000, "maximum number of responses a GreetManyTimes request may ask for")
+	maxStreamInterval := flag.Duration("max-stream-interval", time.Minute, "maximum interval plus jitter a GreetManyTimes request may ask for")
+	grpcWebAddr := flag.String("grpc-web-addr", "", "address to serve gRPC-Web over TLS on, e.g. :8443 (empty to disable)")
+	grpcWebOrigins := flag.String("grpc-web-origins", "", "comma separated origins allowed to call gRPC-Web cross-origin (* for any)")
+	mux := flag.Bool("mux", false, "serve gRPC, gRPC-Web, the REST gateway and /metrics all on the gRPC port")
+	gatewayCA := flag.String("gateway-ca", "ssl/ca.crt", "CA certificate the REST gateway verifies this server with (used with -mux)")
+	gatewayCert := flag.String("gateway-cert", "ssl/client.crt", "client certificate the REST gateway presents to this server (used with -mux and -mtls)")
+	gatewayKey := flag.String("gateway-key", "ssl/client.pem", "client private key of -gateway-cert (used with -mux and -mtls)")
+	metricsAddr := flag.String("metrics-addr", ":9091", "address to serve Prometheus /metrics on (empty to disable)")
+	traceExporter := flag.String("trace-exporter", telemetry.TraceExporterNone, "trace exporter: none, stdout or otlp")
+	otlpEndpoint := flag.String("otlp-endpoint", "localhost:4317", "OTLP/gRPC collector endpoint (used with -trace-exporter otlp)")
+	faultErrorRate := flag.Float64("fault-error-rate", 0, "fraction of RPCs to fail with -fault-code before they reach the handler, for testing client retries")
+	faultCode := flag.String("fault-code", "UNAVAILABLE", "status code of injected failures")
+	faultDelayRate := flag.Float64("fault-delay-rate", 0, "fraction of RPCs to delay by -fault-delay, for testing client hedging")
+	faultDelay := flag.Duration("fault-delay", time.Second, "delay of delayed RPCs")
+	faultMethods := flag.String("fault-methods", "", "comma separated full method names, or prefixes, to inject faults into (empty for all)")
+	reloadInterval := flag.Duration("cert-reload-interval", 10*time.Second, "interval to check the certificate files for changes (0 to reload on SIGHUP only)")
+	flag.Parse()
 
-func (*server) Greet(ctx context.Context, req *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
-	fmt.Printf("Greet function was invoked with %v\n", req)
-	firstName := req.GetGreeting().GetFirstName()
-	result := "Hello " + firstName
-	rsp := &greetpb.GreetResponse{
-		Result: result,
-	}
-	return rsp, nil
-}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	slog.SetDefault(logger)
 
-func (*server) GreetManyTimes(req *greetpb.GreetManyTimesRequest, stream greetpb.GreetService_GreetManyTimesServer) error {
-	fmt.Printf("GreetManyTimes function was invoke with req:%+v\n", req)
-	firstName := req.GetGreeting().FirstName
-	for i := 0; i < 10; i++ {
-		result := "Hello " + firstName + " number:" + strconv.Itoa(i)
-		rsp := &greetpb.GreetManyTimesResponse{
-			Result: result,
+	cfg, err := config.Load(flag.CommandLine, "GREET_SERVER", *configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	listeners, err := config.ParseListeners(*listenAddrs, true, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *mtls {
+		// Plaintext listeners would let clients in without a certificate.
+		for _, l := range listeners {
+			if !l.TLS {
+				log.Fatalf("-mtls requires TLS on every listener, but %v is plaintext", l)
+			}
 		}
-		stream.Send(rsp)
-		time.Sleep(1000 * time.Millisecond)
 	}
-	return nil
-}
+	cfg.Log(logger)
+
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		ServiceName:  "greet-server",
+		Exporter:     *traceExporter,
+		OTLPEndpoint: *otlpEndpoint,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func (*server) LongGreet(stream greetpb.GreetService_LongGreetServer) error {
-	fmt.Println("LongGreet request received")
-	result := ""
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			return stream.SendAndClose(&greetpb.LongGreetResponse{
-				Result: result,
-			})
+	liss, err := listen(listeners)
+	if err != nil {
+		log.Fatalf("Failed to listen:%v", err)
+	}
+	certs, err := newCertReloader(*certFile, *keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	done := make(chan struct{})
+	go certs.Watch(*reloadInterval, done)
+	caFile := ""
+	if *mtls {
+		caFile = *clientCAFile
+	}
+	creds, err := newServerCreds(certs, caFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := newDrainer()
+	reg := telemetry.NewRegistry()
+	reg.MustRegister(certs)
+	metrics := interceptor.NewMetrics(reg)
+	opts := append(interceptor.ServerOptions(logger, metrics),
+		grpc.Creds(mixedCreds(creds)),
+		telemetry.ServerStatsHandler(),
+	)
+	if *faultErrorRate > 0 || *faultDelayRate > 0 {
+		code, err := interceptor.ParseCode(*faultCode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f := &interceptor.Faults{
+			ErrorRate: *faultErrorRate,
+			Code:      code,
+			DelayRate: *faultDelayRate,
+			Delay:     *faultDelay,
+			Methods:   splitList(*faultMethods),
 		}
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(f.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(f.StreamInterceptor()),
+		)
+	}
+	if *jwksFile != "" {
+		v, err := auth.NewJWTValidator(auth.JWTConfig{
+			JWKSFile: *jwksFile,
+			Issuer:   *jwtIssuer,
+			Audience: *jwtAudience,
+			SkipMethods: []string{
+				"/grpc.health.v1.Health/",
+				"/grpc.reflection.",
+			},
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		firstName := req.Greeting.FirstName
-		result += "Hello " + firstName + "! "
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(v.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(v.StreamInterceptor()),
+		)
 	}
-}
-
-func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) error {
-	fmt.Println("GreetEveryone request received")
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			return nil
+	if *authzPolicy != "" {
+		e, err := authz.NewEngine(*authzPolicy)
+		if err != nil {
+			log.Fatal(err)
 		}
+		go e.Watch(*authzReloadInterval, done)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(e.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(e.StreamInterceptor()),
+		)
+	}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(validate.NameRules.UnaryInterceptor(), d.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(validate.NameRules.StreamInterceptor(), d.StreamInterceptor),
+	)
+	s := grpc.NewServer(opts...)
+	greetpb.RegisterGreetServiceServer(s, greetservice.NewServer(greetservice.StreamLimits{
+		MaxCount:    *maxStreamCount,
+		MaxInterval: *maxStreamInterval,
+	}))
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	if *enableReflection {
+		reflection.Register(s)
+	}
+
+	for service := range s.GetServiceInfo() {
+		hs.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	// Tracks the RPCs served through HTTP handlers, which GracefulStop
+	// cannot drain.
+	tracker := &web.Tracker{}
+	var metricsSrv, webSrv *http.Server
+	var muxSrvs []*http.Server
+	if *mux {
+		tlsCfg, err := newServerTLSConfig(certs, caFile)
 		if err != nil {
 			log.Fatal(err)
-			return err
 		}
-		firstName := req.Greeting.FirstName
-		result := "Hello " + firstName + "! "
-		if err := stream.Send(&greetpb.GreetEveryoneResponse{
-			Result: result,
-		}); err != nil {
+		gw, err := newGateway(listeners[0], *gatewayCA, *gatewayCert, *gatewayKey, *mtls)
+		if err != nil {
 			log.Fatal(err)
-			return err
+		}
+		hm := http.NewServeMux()
+		hm.Handle("/metrics", telemetry.MetricsHandler(reg))
+		hm.Handle("/", gw)
+		h := tracker.Wrap(web.Multiplex(s, web.NewHandler(s, splitList(*grpcWebOrigins), hm)))
+		log.Println("Listening greeting request, gRPC-Web, REST and metrics...")
+		for _, lis := range liss {
+			if isPlaintext(lis) {
+				muxSrvs = append(muxSrvs, web.ServeH2C(lis, h, tracker))
+			} else {
+				muxSrvs = append(muxSrvs, web.ServeTLS(lis, h, tlsCfg))
+			}
+		}
+	} else {
+		if *metricsAddr != "" {
+			metricsSrv = telemetry.ServeMetrics(*metricsAddr, reg)
+		}
+		if *grpcWebAddr != "" {
+			tlsCfg, err := newServerTLSConfig(certs, caFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			webSrv = web.Serve(*grpcWebAddr, tracker.Wrap(web.NewHandler(s, splitList(*grpcWebOrigins), nil)), tlsCfg)
+		}
+		for i, lis := range liss {
+			go func(l config.Listener, lis net.Listener) {
+				log.Printf("Listening greeting request on %v...\n", l)
+				if err := s.Serve(lis); err != nil {
+					log.Fatalf("Failed to serve:%v", err)
+				}
+			}(listeners[i], lis)
 		}
 	}
-}
 
-func (*server) GreetWithDeadline(ctx context.Context, req *greetpb.GreetWithDeadlineRequest) (*greetpb.GreetWithDeadlineResponse, error) {
-	log.Printf("GreetWithDeadline req = %+v\n", req)
-	for i := 0; i < 4; i++ {
-		log.Println("...")
-		if ctx.Err() == context.Canceled {
-			log.Println("👀client canceled the request")
-			return nil, status.Error(codes.DeadlineExceeded, "client canceled the request")
-		}
-		time.Sleep(1 * time.Second)
+	q := make(chan os.Signal, 1)
+	signal.Notify(q, os.Interrupt, syscall.SIGTERM)
+	sig := <-q
+	log.Printf("👋 Received %v, stopping gRPC server\n", sig)
+	close(done)
+	hs.Shutdown()
+	https := muxSrvs
+	if webSrv != nil {
+		https = append(https, webSrv)
 	}
-	firstName := req.Greeting.FirstName
-	result := "Hello " + firstName
-	rsp := &greetpb.GreetWithDeadlineResponse{
-		Result: result,
+	shutdown(s, d, *drainTimeout, tracker, https...)
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Printf("Failed to flush traces:%v\n", err)
 	}
-	log.Printf("result = %+v\n", result)
-	return rsp, nil
 }
 
-func main() {
-	lis, err := net.Listen("tcp", "0.0.0.0:50051")
-	if err != nil {
-		log.Fatalf("Failed to listen:%v", err)
-	}
-	certFile := "ssl/server.crt"
-	keyFile := "ssl/server.pem"
-	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-	opts := grpc.Creds(creds)
-	s := grpc.NewServer(opts)
-	greetpb.RegisterGreetServiceServer(s, &server{})
-	fmt.Println("Listening greeting request...")
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve:%v", err)
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
 	}
+	return l
 }
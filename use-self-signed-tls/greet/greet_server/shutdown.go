@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/web"
+	"google.golang.org/grpc"
+)
+
+// cancelGracePeriod is how long handlers are given to return after their
+// context was canceled before the server is stopped forcibly.
+const cancelGracePeriod = 1 * time.Second
+
+// drainer lets in-flight RPCs be canceled through their context when the
+// server does not drain in time.
+type drainer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newDrainer() *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{ctx: ctx, cancel: cancel}
+}
+
+// withDrain returns a context that is canceled when either ctx or the
+// drainer is canceled.
+func (d *drainer) withDrain(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-d.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (d *drainer) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, cancel := d.withDrain(ctx)
+	defer cancel()
+	return handler(ctx, req)
+}
+
+func (d *drainer) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, cancel := d.withDrain(ss.Context())
+	defer cancel()
+	return handler(srv, &drainStream{ServerStream: ss, ctx: ctx})
+}
+
+type drainStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *drainStream) Context() context.Context {
+	return s.ctx
+}
+
+// shutdown stops accepting new RPCs and waits up to timeout for in-flight
+// RPCs to finish. Remaining RPCs are then canceled via their context and the
+// server is stopped.
+//
+// https are the HTTP servers calling s through ServeHTTP, such as gRPC-Web,
+// and t tracks the RPCs they serve. GracefulStop cannot drain RPCs served
+// that way, so the HTTP servers are shut down and t drained first.
+func shutdown(s *grpc.Server, d *drainer, timeout time.Duration, t *web.Tracker, https ...*http.Server) {
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+	stopped := make(chan struct{})
+	go func() {
+		for _, srv := range https {
+			srv.Shutdown(drainCtx)
+		}
+		t.Drain(drainCtx)
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Println("All RPCs drained")
+		return
+	case <-time.After(timeout):
+	}
+	log.Printf("Drain timeout %v exceeded, canceling in-flight RPCs\n", timeout)
+	d.cancel()
+	select {
+	case <-stopped:
+		return
+	case <-time.After(cancelGracePeriod):
+	}
+	log.Println("Forcing server stop")
+	// Stop s before giving up on the HTTP servers so that GracefulStop
+	// finds no transport to drain.
+	s.Stop()
+	cancelDrain()
+	for _, srv := range https {
+		srv.Close()
+	}
+	<-stopped
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TestShutdownDuringH2CStream stops a -mux server with SIGTERM while an RPC
+// is streaming over a plaintext (h2c) listener, served through ServeHTTP.
+func TestShutdownDuringH2CStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the server binary")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "greet_server")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build:%v\n%s", err, out)
+	}
+	certs := filepath.Join(dir, "ssl")
+	if out, err := exec.Command("go", "run", "../../cmd/certgen", "-out", certs, "-key-type", "ecdsa").CombinedOutput(); err != nil {
+		t.Fatalf("certgen:%v\n%s", err, out)
+	}
+	h2cSock := filepath.Join(dir, "h2c.sock")
+	var out bytes.Buffer
+	cmd := exec.Command(bin,
+		"-mux",
+		"-listen", "tls+unix://"+filepath.Join(dir, "tls.sock")+",unix://"+h2cSock,
+		"-cert", filepath.Join(certs, "server.crt"),
+		"-key", filepath.Join(certs, "server.pem"),
+		"-gateway-ca", filepath.Join(certs, "ca.crt"),
+		"-metrics-addr", "",
+		"-drain-timeout", "10s",
+	)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	var exitErr error
+	go func() {
+		exitErr = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		select {
+		case <-exited:
+		default:
+			cmd.Process.Kill()
+			<-exited
+		}
+	}()
+
+	cc, err := grpc.Dial("unix://"+h2cSock, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	const count = 5
+	stream, err := greetpb.NewGreetServiceClient(cc).GreetManyTimes(ctx, &greetpb.GreetManyTimesRequest{
+		Greeting:   &greetpb.Greeting{FirstName: "John"},
+		Count:      count,
+		IntervalMs: 300,
+	}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first response:%v", err)
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	n := 1
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream failed after %d responses during shutdown:%v", n, err)
+		}
+		n++
+	}
+	if n != count {
+		t.Errorf("got %d responses, want %d", n, count)
+	}
+
+	select {
+	case <-exited:
+		if exitErr != nil {
+			t.Errorf("server exited with %v", exitErr)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("server did not exit after SIGTERM")
+	}
+	if strings.Contains(out.String(), "panic") {
+		t.Errorf("server panicked:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "All RPCs drained") {
+		t.Errorf("server did not drain:\n%s", out.String())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -150,10 +151,16 @@ func (m *GreetResponse) GetResult() string {
 }
 
 type GreetManyTimesRequest struct {
-	Greeting             *Greeting `protobuf:"bytes,1,opt,name=greeting,proto3" json:"greeting,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+	Greeting *Greeting `protobuf:"bytes,1,opt,name=greeting,proto3" json:"greeting,omitempty"`
+	// Number of responses to stream. Defaults to 10 when 0.
+	Count int32 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	// Interval between responses in milliseconds. Defaults to 1000 when 0.
+	IntervalMs int32 `protobuf:"varint,3,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	// Upper bound of a random delay in milliseconds added to each interval.
+	JitterMs             int32    `protobuf:"varint,4,opt,name=jitter_ms,json=jitterMs,proto3" json:"jitter_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *GreetManyTimesRequest) Reset()         { *m = GreetManyTimesRequest{} }
@@ -188,6 +195,27 @@ func (m *GreetManyTimesRequest) GetGreeting() *Greeting {
 	return nil
 }
 
+func (m *GreetManyTimesRequest) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *GreetManyTimesRequest) GetIntervalMs() int32 {
+	if m != nil {
+		return m.IntervalMs
+	}
+	return 0
+}
+
+func (m *GreetManyTimesRequest) GetJitterMs() int32 {
+	if m != nil {
+		return m.JitterMs
+	}
+	return 0
+}
+
 type GreetManyTimesResponse struct {
 	Result               string   `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -478,31 +506,38 @@ func init() {
 func init() { proto.RegisterFile("greet/greetpb/greet.proto", fileDescriptor_fe6f881da19a2871) }
 
 var fileDescriptor_fe6f881da19a2871 = []byte{
-	// 373 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x94, 0x4f, 0x4f, 0xc2, 0x40,
-	0x10, 0xc5, 0xad, 0x06, 0xa4, 0xe3, 0x5f, 0x46, 0x44, 0x28, 0x10, 0x49, 0x2f, 0x92, 0x90, 0x00,
-	0x01, 0x6f, 0x1e, 0x4c, 0x10, 0xe5, 0xa2, 0x46, 0xd1, 0x44, 0xe3, 0xc5, 0x14, 0x1d, 0x6b, 0x13,
-	0x68, 0xb1, 0x5d, 0x48, 0xf8, 0x04, 0x7e, 0x6d, 0xc3, 0xb2, 0x0b, 0xa5, 0x80, 0x4d, 0x7a, 0x69,
-	0xbb, 0xfb, 0xde, 0xfe, 0xde, 0x76, 0x67, 0xb2, 0x90, 0x35, 0x5d, 0x22, 0x56, 0xe5, 0xcf, 0x41,
-	0x77, 0xfa, 0xae, 0x0c, 0x5c, 0x87, 0x39, 0x18, 0xe3, 0x03, 0xfd, 0x06, 0x12, 0xed, 0xc9, 0x87,
-	0x65, 0x9b, 0x58, 0x00, 0xf8, 0xb2, 0x5c, 0x8f, 0xbd, 0xdb, 0x46, 0x9f, 0x32, 0x4a, 0x51, 0x29,
-	0xa9, 0x1d, 0x95, 0xcf, 0xdc, 0x1b, 0x7d, 0xc2, 0x1c, 0xa8, 0x3d, 0x43, 0xaa, 0x9b, 0x5c, 0x4d,
-	0x4c, 0x26, 0x26, 0xa2, 0x7e, 0x01, 0xbb, 0x9c, 0xd3, 0xa1, 0x9f, 0x21, 0x79, 0x0c, 0xcb, 0x90,
-	0x30, 0x05, 0x97, 0x93, 0x76, 0xea, 0x07, 0x95, 0x69, 0xbc, 0x8c, 0xeb, 0xcc, 0x0c, 0xfa, 0x19,
-	0xec, 0x89, 0xc5, 0xde, 0xc0, 0xb1, 0x3d, 0xc2, 0x34, 0xc4, 0x5d, 0xf2, 0x86, 0x3d, 0x26, 0x76,
-	0x21, 0x46, 0x7a, 0x0b, 0x8e, 0xb9, 0xf1, 0xce, 0xb0, 0xc7, 0xcf, 0x56, 0x9f, 0xbc, 0x48, 0x71,
-	0x35, 0x48, 0x07, 0x29, 0x21, 0xb9, 0x97, 0x70, 0x78, 0xeb, 0xd8, 0x66, 0xf4, 0x3f, 0x2c, 0x43,
-	0xd2, 0x07, 0x08, 0x49, 0xbb, 0x82, 0x14, 0x37, 0x5e, 0x8f, 0xc8, 0x1d, 0x3b, 0x36, 0x45, 0x4a,
-	0xac, 0x8a, 0xa3, 0x9a, 0x43, 0x42, 0x52, 0xdb, 0x90, 0xe1, 0x0b, 0x5e, 0x2c, 0xf6, 0xdd, 0x22,
-	0xe3, 0xb3, 0x67, 0x45, 0x4c, 0x6e, 0x40, 0x76, 0x05, 0xe8, 0xff, 0xf4, 0xfa, 0xef, 0x96, 0x68,
-	0xa0, 0x27, 0x72, 0x47, 0xd6, 0x07, 0xe1, 0x39, 0xc4, 0xf8, 0x18, 0x8f, 0xfc, 0x49, 0x62, 0x43,
-	0x5a, 0x6a, 0x71, 0x72, 0x0a, 0xd7, 0x37, 0xf0, 0x11, 0xf6, 0x17, 0x4b, 0x8b, 0x79, 0xbf, 0x33,
-	0xd8, 0x37, 0x5a, 0x61, 0x8d, 0x2a, 0x81, 0x35, 0x05, 0x9b, 0xa0, 0xce, 0x4a, 0x87, 0x27, 0xc2,
-	0x1f, 0xec, 0x06, 0x2d, 0xb3, 0x2c, 0x48, 0x46, 0x49, 0xc1, 0x07, 0xd1, 0xe0, 0xb2, 0x18, 0x98,
-	0xf3, 0xe7, 0x06, 0xea, 0xac, 0xe5, 0x57, 0x8b, 0x73, 0x5e, 0x4d, 0xc1, 0x57, 0x48, 0x2e, 0x1d,
-	0x32, 0x9e, 0xfa, 0x17, 0xae, 0xa8, 0xa3, 0x56, 0x5c, 0x6f, 0x90, 0xf4, 0xa6, 0xfa, 0xb6, 0x2d,
-	0xee, 0x8b, 0x6e, 0x9c, 0x5f, 0x15, 0x8d, 0xbf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x01, 0x6f, 0x0a,
-	0x03, 0x47, 0x04, 0x00, 0x00,
+	// 489 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0xcd, 0x6e, 0xd3, 0x40,
+	0x10, 0xc6, 0x2d, 0x29, 0xf1, 0x94, 0x06, 0x3a, 0x4d, 0xdb, 0xc4, 0x4d, 0xd4, 0xca, 0x17, 0x2a,
+	0x2a, 0xd5, 0xa1, 0xbd, 0xc1, 0x01, 0xa9, 0xfc, 0x94, 0x03, 0x41, 0x28, 0x20, 0x21, 0x71, 0xa9,
+	0xb6, 0xed, 0x60, 0xb6, 0xb2, 0x77, 0x83, 0x77, 0x13, 0x29, 0x57, 0x5e, 0x81, 0x0b, 0xaf, 0x85,
+	0x78, 0x05, 0x1e, 0x04, 0x79, 0x77, 0x93, 0x3a, 0x6e, 0xda, 0x48, 0xb9, 0x24, 0x9a, 0xf9, 0xbe,
+	0xf9, 0xbe, 0xf1, 0xcc, 0xd8, 0xd0, 0x8c, 0x33, 0x22, 0x1d, 0x99, 0xdf, 0xfe, 0xb9, 0xfd, 0x3f,
+	0xec, 0x67, 0x52, 0x4b, 0xac, 0x98, 0x20, 0x68, 0xc5, 0x52, 0xc6, 0x09, 0x45, 0xac, 0xcf, 0x23,
+	0x26, 0x84, 0xd4, 0x4c, 0x73, 0x29, 0x94, 0x25, 0x85, 0x6f, 0xa1, 0x7a, 0x9a, 0xd3, 0xb8, 0x88,
+	0xb1, 0x0d, 0xf0, 0x8d, 0x67, 0x4a, 0x9f, 0x09, 0x96, 0x52, 0xc3, 0xdb, 0xf3, 0xf6, 0xfd, 0x9e,
+	0x6f, 0x32, 0x1f, 0x58, 0x4a, 0xb8, 0x03, 0x7e, 0xc2, 0xc6, 0xe8, 0x92, 0x41, 0xab, 0x09, 0xb3,
+	0x60, 0xf8, 0x02, 0x1e, 0x1a, 0x9d, 0x1e, 0xfd, 0x18, 0x90, 0xd2, 0x78, 0x00, 0xd5, 0xd8, 0xe9,
+	0x1a, 0xa5, 0xd5, 0xa3, 0x47, 0x87, 0xb6, 0xb9, 0xb1, 0x5d, 0x6f, 0x42, 0x08, 0x9f, 0xc0, 0x9a,
+	0x2b, 0x56, 0x7d, 0x29, 0x14, 0xe1, 0x16, 0xac, 0x64, 0xa4, 0x06, 0x89, 0x76, 0x5d, 0xb8, 0x28,
+	0xfc, 0xed, 0xc1, 0xa6, 0x61, 0x76, 0x99, 0x18, 0x7d, 0xe6, 0x29, 0xa9, 0x45, 0xfc, 0xb0, 0x0e,
+	0x95, 0x0b, 0x39, 0x10, 0xda, 0x3c, 0x45, 0xa5, 0x67, 0x03, 0xdc, 0x85, 0x55, 0x2e, 0x34, 0x65,
+	0x43, 0x96, 0x9c, 0xa5, 0xaa, 0xb1, 0x6c, 0x30, 0x18, 0xa7, 0xba, 0x2a, 0x1f, 0xc0, 0x15, 0xd7,
+	0x9a, 0xb2, 0x1c, 0xbe, 0x6f, 0xe0, 0xaa, 0x4d, 0x74, 0x55, 0xd8, 0x81, 0xad, 0x72, 0x67, 0x73,
+	0x1e, 0xe6, 0x25, 0x3c, 0x7e, 0x2f, 0x45, 0xbc, 0xf8, 0xd8, 0x0e, 0x60, 0xbd, 0x20, 0x30, 0xc7,
+	0xed, 0x15, 0xd4, 0x0d, 0xf1, 0xcd, 0x90, 0xb2, 0x91, 0x14, 0xb4, 0x90, 0x63, 0x04, 0x9b, 0x25,
+	0x91, 0x39, 0xae, 0xa7, 0xd0, 0x30, 0x05, 0x5f, 0xb8, 0xfe, 0xfe, 0x9a, 0xd8, 0x65, 0xc2, 0x17,
+	0x74, 0x3e, 0x86, 0xe6, 0x0c, 0xa1, 0xbb, 0xdd, 0x8f, 0xfe, 0x2c, 0xbb, 0xab, 0xfc, 0x44, 0xd9,
+	0x90, 0x5f, 0x10, 0xbe, 0x83, 0x8a, 0x89, 0x71, 0xa3, 0xe8, 0xe4, 0x1a, 0x0a, 0xea, 0xd3, 0x49,
+	0x2b, 0x1e, 0xd6, 0x7f, 0xfe, 0xfd, 0xf7, 0x6b, 0xa9, 0xf6, 0xdc, 0x7b, 0x1a, 0xfa, 0xd1, 0xf0,
+	0x99, 0x7d, 0xc5, 0xf0, 0x0a, 0x6a, 0xd3, 0xeb, 0xc6, 0x56, 0xb1, 0xba, 0x7c, 0x9f, 0x41, 0xfb,
+	0x16, 0xd4, 0x99, 0x34, 0x8d, 0xc9, 0x46, 0x6e, 0x52, 0x9b, 0x98, 0x44, 0x29, 0x13, 0xa3, 0x8e,
+	0x87, 0x27, 0xe0, 0x4f, 0xf6, 0x8c, 0xdb, 0x4e, 0xa8, 0x7c, 0x3a, 0x41, 0xe3, 0x26, 0xe0, 0xc4,
+	0xef, 0xed, 0x7b, 0xf8, 0x11, 0xd6, 0xa6, 0x36, 0x87, 0x3b, 0xc5, 0x86, 0x4a, 0x47, 0x11, 0xb4,
+	0x66, 0x83, 0xd7, 0x7a, 0x1d, 0x0f, 0x07, 0xb0, 0x7e, 0x63, 0x23, 0xb8, 0x5b, 0x2c, 0x9c, 0xb1,
+	0xf4, 0x60, 0xef, 0x76, 0x82, 0x53, 0x6f, 0x9b, 0x51, 0x6c, 0xe7, 0xa3, 0xc0, 0xeb, 0x51, 0x5c,
+	0x3a, 0xda, 0x89, 0xff, 0xf5, 0x81, 0xfb, 0xd8, 0x9d, 0xaf, 0x98, 0x4f, 0xd8, 0xf1, 0xff, 0x01,
+	0x00, 0xe8, 0xf4, 0x07, 0x1d, 0x04, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -0,0 +1,45 @@
+package greetservice
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rpcLogger returns the default logger annotated with the request ID of the
+// RPC.
+func rpcLogger(ctx context.Context) *slog.Logger {
+	return slog.Default().With("request_id", interceptor.RequestIDFromContext(ctx))
+}
+
+// streamError classifies an error returned by Recv or Send on a stream into
+// a gRPC status error, so that a broken stream only ends its own RPC.
+func streamError(ctx context.Context, method string, err error) error {
+	rpcLogger(ctx).Warn("stream error", "method", method, "err", err)
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Err()
+	}
+	if err == io.ErrUnexpectedEOF {
+		return status.Error(codes.Unavailable, "stream transport closed unexpectedly")
+	}
+	return status.Errorf(codes.Internal, "stream failed:%v", err)
+}
+
+// contextError converts the error of a done context into a gRPC status error.
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return status.Error(codes.Canceled, "client canceled the request")
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package greetservice
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultManyTimesCount    = 10
+	defaultManyTimesInterval = 1000 * time.Millisecond
+)
+
+// manyTimesParams is the validated cadence of a GreetManyTimes stream.
+type manyTimesParams struct {
+	count    int
+	interval time.Duration
+	jitter   time.Duration
+}
+
+func (l StreamLimits) manyTimesParams(req *greetpb.GreetManyTimesRequest) (*manyTimesParams, error) {
+	if req.GetCount() < 0 || req.GetIntervalMs() < 0 || req.GetJitterMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "count, interval_ms and jitter_ms must not be negative")
+	}
+	p := &manyTimesParams{
+		count:    int(req.GetCount()),
+		interval: time.Duration(req.GetIntervalMs()) * time.Millisecond,
+		jitter:   time.Duration(req.GetJitterMs()) * time.Millisecond,
+	}
+	if p.count == 0 {
+		p.count = defaultManyTimesCount
+	}
+	if p.interval == 0 {
+		p.interval = defaultManyTimesInterval
+	}
+	if p.count > l.MaxCount {
+		return nil, status.Errorf(codes.InvalidArgument, "count %d exceeds the maximum %d", p.count, l.MaxCount)
+	}
+	if p.interval+p.jitter > l.MaxInterval {
+		return nil, status.Errorf(codes.InvalidArgument, "interval_ms + jitter_ms %v exceeds the maximum %v", p.interval+p.jitter, l.MaxInterval)
+	}
+	return p, nil
+}
+
+// delay returns the interval plus a random jitter.
+func (p *manyTimesParams) delay() time.Duration {
+	if p.jitter <= 0 {
+		return p.interval
+	}
+	return p.interval + time.Duration(rand.Int63n(int64(p.jitter)))
+}
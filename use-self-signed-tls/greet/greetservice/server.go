@@ -0,0 +1,126 @@
+// Package greetservice implements greet.GreetService as served by
+// greet_server.
+package greetservice
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/auth"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+)
+
+// StreamLimits are the server side maximums of GreetManyTimes parameters.
+type StreamLimits struct {
+	// MaxCount is the maximum number of responses a request may ask for.
+	MaxCount int
+	// MaxInterval is the maximum interval plus jitter a request may ask for.
+	MaxInterval time.Duration
+}
+
+type server struct {
+	limits StreamLimits
+}
+
+// NewServer returns the GreetService handlers, limiting GreetManyTimes
+// streams to limits.
+func NewServer(limits StreamLimits) greetpb.GreetServiceServer {
+	return &server{limits: limits}
+}
+
+func (*server) Greet(ctx context.Context, req *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
+	if id, ok := auth.PeerIdentity(ctx); ok {
+		rpcLogger(ctx).Info("Greet caller", "cn", id.CommonName, "sans", id.DNSNames)
+	}
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		rpcLogger(ctx).Info("Greet caller", "sub", claims.Subject, "roles", claims.Roles)
+	}
+	firstName := req.GetGreeting().GetFirstName()
+	result := "Hello " + firstName
+	rsp := &greetpb.GreetResponse{
+		Result: result,
+	}
+	return rsp, nil
+}
+
+func (s *server) GreetManyTimes(req *greetpb.GreetManyTimesRequest, stream greetpb.GreetService_GreetManyTimesServer) error {
+	params, err := s.limits.manyTimesParams(req)
+	if err != nil {
+		return err
+	}
+	firstName := req.GetGreeting().GetFirstName()
+	for i := 0; i < params.count; i++ {
+		result := "Hello " + firstName + " number:" + strconv.Itoa(i)
+		rsp := &greetpb.GreetManyTimesResponse{
+			Result: result,
+		}
+		if err := stream.Send(rsp); err != nil {
+			return streamError(stream.Context(), "GreetManyTimes", err)
+		}
+		if i == params.count-1 {
+			break
+		}
+		select {
+		case <-stream.Context().Done():
+			rpcLogger(stream.Context()).Info("GreetManyTimes stopped", "err", stream.Context().Err())
+			return contextError(stream.Context())
+		case <-time.After(params.delay()):
+		}
+	}
+	return nil
+}
+
+func (*server) LongGreet(stream greetpb.GreetService_LongGreetServer) error {
+	result := ""
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&greetpb.LongGreetResponse{
+				Result: result,
+			})
+		}
+		if err != nil {
+			return streamError(stream.Context(), "LongGreet", err)
+		}
+		firstName := req.Greeting.FirstName
+		result += "Hello " + firstName + "! "
+	}
+}
+
+func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return streamError(stream.Context(), "GreetEveryone", err)
+		}
+		firstName := req.Greeting.FirstName
+		result := "Hello " + firstName + "! "
+		if err := stream.Send(&greetpb.GreetEveryoneResponse{
+			Result: result,
+		}); err != nil {
+			return streamError(stream.Context(), "GreetEveryone", err)
+		}
+	}
+}
+
+func (*server) GreetWithDeadline(ctx context.Context, req *greetpb.GreetWithDeadlineRequest) (*greetpb.GreetWithDeadlineResponse, error) {
+	for i := 0; i < 4; i++ {
+		select {
+		case <-ctx.Done():
+			rpcLogger(ctx).Info("GreetWithDeadline stopped", "err", ctx.Err())
+			return nil, contextError(ctx)
+		case <-time.After(1 * time.Second):
+		}
+	}
+	firstName := req.Greeting.FirstName
+	result := "Hello " + firstName
+	rsp := &greetpb.GreetWithDeadlineResponse{
+		Result: result,
+	}
+	return rsp, nil
+}
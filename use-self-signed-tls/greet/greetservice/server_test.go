@@ -0,0 +1,145 @@
+package greetservice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// handlerErrors records the errors the stream handlers return, before the
+// transport turns them into the status the client sees.
+type handlerErrors chan error
+
+func (h handlerErrors) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	h <- err
+	return err
+}
+
+// next returns the error of the next stream handler to return.
+func (h handlerErrors) next(t *testing.T) error {
+	t.Helper()
+	select {
+	case err := <-h:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream handler did not return")
+		return nil
+	}
+}
+
+// assertCanceled checks that a handler returned the status of a client
+// cancellation rather than a raw stream error. Depending on whether the
+// cancellation reaches the stream or its context first, the message is
+// that of either.
+func assertCanceled(t *testing.T, err error) {
+	t.Helper()
+	if _, ok := status.FromError(err); !ok {
+		t.Fatalf("handler returned %v, want a status error", err)
+	}
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("handler returned %v, want Canceled", err)
+	}
+}
+
+func newTestClient(t *testing.T) (greetpb.GreetServiceClient, handlerErrors) {
+	t.Helper()
+	h := make(handlerErrors, 10)
+	return greettest.NewClient(t, grpc.StreamInterceptor(h.stream)), h
+}
+
+func greeting(name string) *greetpb.Greeting {
+	return &greetpb.Greeting{FirstName: name, LastName: "Doe"}
+}
+
+// assertGreet checks that the server still serves Greet.
+func assertGreet(t *testing.T, c greetpb.GreetServiceClient) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rsp, err := c.Greet(ctx, &greetpb.GreetRequest{Greeting: greeting("John")})
+	if err != nil {
+		t.Fatalf("Greet after aborted stream:%v", err)
+	}
+	if got, want := rsp.GetResult(), "Hello John"; got != want {
+		t.Fatalf("Greet = %q, want %q", got, want)
+	}
+}
+
+func TestAbortedLongGreet(t *testing.T) {
+	c, h := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := c.LongGreet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"Alice", "Bob"} {
+			if err := stream.Send(&greetpb.LongGreetRequest{Greeting: greeting(name)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cancel()
+		if _, err := stream.CloseAndRecv(); status.Code(err) != codes.Canceled {
+			t.Fatalf("CloseAndRecv after cancel = %v, want Canceled", err)
+		}
+		assertCanceled(t, h.next(t))
+		assertGreet(t, c)
+	}
+}
+
+func TestAbortedGreetEveryone(t *testing.T) {
+	c, h := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := c.GreetEveryone(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: greeting("Alice")}); err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := rsp.GetResult(), "Hello Alice! "; got != want {
+			t.Fatalf("GreetEveryone = %q, want %q", got, want)
+		}
+		cancel()
+		if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+			t.Fatalf("Recv after cancel = %v, want Canceled", err)
+		}
+		assertCanceled(t, h.next(t))
+		assertGreet(t, c)
+	}
+}
+
+func TestAbortedGreetManyTimes(t *testing.T) {
+	c, h := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.GreetManyTimes(ctx, &greetpb.GreetManyTimesRequest{
+		Greeting:   greeting("Alice"),
+		Count:      10,
+		IntervalMs: 10000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("Recv after cancel = %v, want Canceled", err)
+	}
+	// The handler stops at once rather than after the 10s interval.
+	assertCanceled(t, h.next(t))
+	assertGreet(t, c)
+}
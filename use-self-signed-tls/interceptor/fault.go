@@ -0,0 +1,99 @@
+package interceptor
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Faults injects failures and delays into RPCs before they reach the
+// handler, to exercise the retry and hedging behavior of clients.
+type Faults struct {
+	// ErrorRate is the fraction of RPCs failing with Code.
+	ErrorRate float64
+	Code      codes.Code
+	// DelayRate is the fraction of RPCs delayed by Delay.
+	DelayRate float64
+	Delay     time.Duration
+	// Methods are the full method names, or prefixes thereof, to inject
+	// faults into. Faults apply to all methods when empty.
+	Methods []string
+	// Rand returns the numbers in [0, 1) that RPCs are delayed or failed
+	// by, drawing one per nonzero rate. It defaults to rand.Float64; tests
+	// set it to inject faults deterministically. It must be safe for
+	// concurrent use.
+	Rand func() float64
+}
+
+// FaultMessage is the message of injected failures, followed by ":" and the
+// full method name.
+const FaultMessage = "injected fault"
+
+// ParseCode parses a status code name as used in service configs, such as
+// "UNAVAILABLE", or its number.
+func ParseCode(s string) (codes.Code, error) {
+	b := []byte(`"` + strings.ToUpper(s) + `"`)
+	if _, err := strconv.Atoi(s); err == nil {
+		b = []byte(s)
+	}
+	var c codes.Code
+	err := c.UnmarshalJSON(b)
+	return c, err
+}
+
+func (f *Faults) inject(ctx context.Context, method string) error {
+	if len(f.Methods) > 0 {
+		matched := false
+		for _, m := range f.Methods {
+			if strings.HasPrefix(method, m) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil
+		}
+	}
+	random := f.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	if f.DelayRate > 0 && random() < f.DelayRate {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(f.Delay):
+		}
+	}
+	if f.ErrorRate > 0 && random() < f.ErrorRate {
+		return status.Errorf(f.Code, FaultMessage+":%v", method)
+	}
+	return nil
+}
+
+// UnaryInterceptor returns the interceptor injecting f into unary RPCs.
+func (f *Faults) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := f.inject(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is the stream counterpart of UnaryInterceptor. Faults
+// are injected before the stream reaches the handler.
+func (f *Faults) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := f.inject(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,28 @@
+// Package interceptor provides gRPC server interceptors shared by the
+// servers in this repository.
+package interceptor
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc"
+)
+
+// ServerOptions returns the server options chaining the request ID, metrics,
+// logging and recovery interceptors, in that order, for unary and stream
+// RPCs. Metrics are skipped when m is nil. Interceptors chained by later
+// options run inside these.
+func ServerOptions(logger *slog.Logger, m *Metrics) []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{UnaryRequestID()}
+	stream := []grpc.StreamServerInterceptor{StreamRequestID()}
+	if m != nil {
+		unary = append(unary, m.UnaryInterceptor())
+		stream = append(stream, m.StreamInterceptor())
+	}
+	unary = append(unary, UnaryLogging(logger), UnaryRecovery(logger))
+	stream = append(stream, StreamLogging(logger), StreamRecovery(logger))
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
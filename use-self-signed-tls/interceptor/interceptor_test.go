@@ -0,0 +1,303 @@
+package interceptor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// panicName makes the handlers panic when greeted.
+const panicName = "Panic"
+
+func panicky(req interface{}) {
+	if r, ok := req.(interface{ GetGreeting() *greetpb.Greeting }); ok && r.GetGreeting().GetFirstName() == panicName {
+		panic("greeted " + panicName)
+	}
+}
+
+// panickyUnary and panickyStream make the handlers panic on requests
+// greeting panicName, as a bug in a handler would.
+func panickyUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	panicky(req)
+	return handler(ctx, req)
+}
+
+func panickyStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &panickyServerStream{ss})
+}
+
+type panickyServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *panickyServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	panicky(m)
+	return nil
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of the
+// server logger.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+// records returns the JSON log records with msg.
+func (b *syncBuffer) records(t *testing.T, msg string) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for dec := json.NewDecoder(bytes.NewReader(b.b.Bytes())); dec.More(); {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r["msg"] == msg {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// newTestClient serves the handlers behind the shared interceptors, logging
+// to the returned buffer.
+func newTestClient(t *testing.T) (greetpb.GreetServiceClient, *syncBuffer) {
+	t.Helper()
+	logs := &syncBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	opts := append(interceptor.ServerOptions(logger, nil),
+		grpc.ChainUnaryInterceptor(panickyUnary),
+		grpc.ChainStreamInterceptor(panickyStream),
+	)
+	return greettest.NewClient(t, opts...), logs
+}
+
+func greeting(name string) *greetpb.Greeting {
+	return &greetpb.Greeting{FirstName: name}
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func assertGreet(t *testing.T, c greetpb.GreetServiceClient) {
+	t.Helper()
+	rsp, err := c.Greet(testContext(t), &greetpb.GreetRequest{Greeting: greeting("John")})
+	if err != nil {
+		t.Fatalf("Greet after panic:%v", err)
+	}
+	if got, want := rsp.GetResult(), "Hello John"; got != want {
+		t.Fatalf("Greet = %q, want %q", got, want)
+	}
+}
+
+func assertInternal(t *testing.T, err error) {
+	t.Helper()
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Fatalf("got %v, want Internal: internal error", err)
+	}
+}
+
+func TestUnaryRecovery(t *testing.T) {
+	c, logs := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		_, err := c.Greet(testContext(t), &greetpb.GreetRequest{Greeting: greeting(panicName)})
+		assertInternal(t, err)
+		assertGreet(t, c)
+	}
+	records := logs.records(t, "panic recovered")
+	if len(records) != 3 {
+		t.Fatalf("logged %d recovered panics, want 3", len(records))
+	}
+	if r := records[0]; r["panic"] != "greeted "+panicName || r["method"] != "/greet.GreetService/Greet" || r["stack"] == "" {
+		t.Errorf("panic record = %v", r)
+	}
+}
+
+func TestStreamRecovery(t *testing.T) {
+	c, logs := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		stream, err := c.GreetEveryone(testContext(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"Alice", panicName} {
+			if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: greeting(name)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("response before panic:%v", err)
+		}
+		_, err = stream.Recv()
+		assertInternal(t, err)
+		assertGreet(t, c)
+	}
+	if n := len(logs.records(t, "panic recovered")); n != 3 {
+		t.Errorf("logged %d recovered panics, want 3", n)
+	}
+}
+
+var generatedID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// checkRequestID checks that the request ID is in exactly one of header and
+// trailer, and that it is sent as want or, if want is "", generated. It
+// returns the ID.
+func checkRequestID(t *testing.T, header, trailer metadata.MD, inHeader bool, want string) string {
+	t.Helper()
+	in, notIn := header, trailer
+	if !inHeader {
+		in, notIn = trailer, header
+	}
+	if v := notIn.Get(interceptor.RequestIDKey); len(v) != 0 {
+		t.Errorf("request ID %v sent in both header and trailer", v)
+	}
+	v := in.Get(interceptor.RequestIDKey)
+	if len(v) != 1 {
+		t.Fatalf("request IDs = %v, want one (header %v, trailer %v)", v, header, trailer)
+	}
+	switch {
+	case want != "" && v[0] != want:
+		t.Errorf("request ID = %q, want %q echoed", v[0], want)
+	case want == "" && !generatedID.MatchString(v[0]):
+		t.Errorf("request ID = %q, want a generated one", v[0])
+	}
+	return v[0]
+}
+
+// checkLogged checks that the RPC with id was logged with it.
+func checkLogged(t *testing.T, logs *syncBuffer, id, code string) {
+	t.Helper()
+	for _, r := range logs.records(t, "rpc") {
+		if r["request_id"] == id {
+			if r["code"] != code {
+				t.Errorf("RPC %s logged with code %v, want %s", id, r["code"], code)
+			}
+			return
+		}
+	}
+	t.Errorf("no RPC logged with request ID %s", id)
+}
+
+func TestUnaryRequestID(t *testing.T) {
+	c, logs := newTestClient(t)
+	for _, tt := range []struct {
+		name, id, firstName string
+		code                codes.Code
+	}{
+		{"echoed", "req-123", "John", codes.OK},
+		{"generated", "", "John", codes.OK},
+		{"echoed on error", "req-456", panicName, codes.Internal},
+		{"generated on error", "", panicName, codes.Internal},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testContext(t)
+			if tt.id != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, interceptor.RequestIDKey, tt.id)
+			}
+			var header, trailer metadata.MD
+			_, err := c.Greet(ctx, &greetpb.GreetRequest{Greeting: greeting(tt.firstName)}, grpc.Header(&header), grpc.Trailer(&trailer))
+			if status.Code(err) != tt.code {
+				t.Fatalf("Greet = %v, want %v", err, tt.code)
+			}
+			id := checkRequestID(t, header, trailer, tt.code == codes.OK, tt.id)
+			checkLogged(t, logs, id, tt.code.String())
+		})
+	}
+}
+
+func TestStreamRequestID(t *testing.T) {
+	c, logs := newTestClient(t)
+	for _, tt := range []struct {
+		name, id string
+		req      *greetpb.GreetManyTimesRequest
+		code     codes.Code
+	}{
+		{"echoed", "req-123", &greetpb.GreetManyTimesRequest{Greeting: greeting("John"), Count: 2, IntervalMs: 1}, codes.OK},
+		{"generated", "", &greetpb.GreetManyTimesRequest{Greeting: greeting("John"), Count: 2, IntervalMs: 1}, codes.OK},
+		// Rejected before the first response, so only the trailer is sent.
+		{"echoed on error", "req-456", &greetpb.GreetManyTimesRequest{Greeting: greeting("John"), Count: -1}, codes.InvalidArgument},
+		{"generated on error", "", &greetpb.GreetManyTimesRequest{Greeting: greeting("John"), Count: -1}, codes.InvalidArgument},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testContext(t)
+			if tt.id != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, interceptor.RequestIDKey, tt.id)
+			}
+			stream, err := c.GreetManyTimes(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for {
+				_, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				n++
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			if status.Code(err) != tt.code {
+				t.Fatalf("GreetManyTimes = %v, want %v", err, tt.code)
+			}
+			if tt.code == codes.OK && n != int(tt.req.GetCount()) {
+				t.Errorf("got %d responses, want %d", n, tt.req.GetCount())
+			}
+			header, err := stream.Header()
+			if err != nil && tt.code == codes.OK {
+				t.Fatal(err)
+			}
+			id := checkRequestID(t, header, stream.Trailer(), tt.code == codes.OK, tt.id)
+			checkLogged(t, logs, id, tt.code.String())
+		})
+	}
+}
+
+// TestRequestIDDownstream checks that the handler context carries the
+// request ID to calls made with it.
+func TestRequestIDDownstream(t *testing.T) {
+	var got, outgoing string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = interceptor.RequestIDFromContext(ctx)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		outgoing = strings.Join(md.Get(interceptor.RequestIDKey), ",")
+		return nil, nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(interceptor.RequestIDKey, "req-789"))
+	// grpc.SetHeader fails outside of a server transport stream; the
+	// context is all this checks.
+	interceptor.UnaryRequestID()(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	if got != "req-789" || outgoing != "req-789" {
+		t.Errorf("handler request ID %q and outgoing %q, want req-789", got, outgoing)
+	}
+}
@@ -0,0 +1,105 @@
+package interceptor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryLogging returns an interceptor emitting one structured log line per
+// RPC with its method, peer, status code, duration and payload sizes.
+func UnaryLogging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		rsp, err := handler(ctx, req)
+		logRPC(ctx, logger, info.FullMethod, start, err,
+			slog.Int("bytes_recv", messageSize(req)),
+			slog.Int("bytes_sent", messageSize(rsp)),
+		)
+		return rsp, err
+	}
+}
+
+// StreamLogging is the stream counterpart of UnaryLogging. It also reports
+// the number of messages received and sent.
+func StreamLogging(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		cs := &countingStream{ServerStream: ss}
+		err := handler(srv, cs)
+		logRPC(ss.Context(), logger, info.FullMethod, start, err,
+			slog.Int("msgs_recv", cs.msgsRecv),
+			slog.Int("msgs_sent", cs.msgsSent),
+			slog.Int("bytes_recv", cs.bytesRecv),
+			slog.Int("bytes_sent", cs.bytesSent),
+		)
+		return err
+	}
+}
+
+func logRPC(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error, attrs ...slog.Attr) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	attrs = append([]slog.Attr{
+		slog.String("method", method),
+		slog.String("peer", peerAddr(ctx)),
+		slog.String("request_id", RequestIDFromContext(ctx)),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	}, attrs...)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	logger.LogAttrs(ctx, level, "rpc", attrs...)
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func messageSize(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+// countingStream counts the messages and bytes going through a stream.
+type countingStream struct {
+	grpc.ServerStream
+	msgsRecv, msgsSent   int
+	bytesRecv, bytesSent int
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.msgsRecv++
+		s.bytesRecv += messageSize(m)
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.msgsSent++
+		s.bytesSent += messageSize(m)
+	}
+	return err
+}
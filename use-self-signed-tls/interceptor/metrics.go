@@ -0,0 +1,144 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics holds the Prometheus collectors of RPC traffic, fed by its
+// interceptors.
+type Metrics struct {
+	started  *prometheus.CounterVec
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	msgsRecv *prometheus.CounterVec
+	msgsSent *prometheus.CounterVec
+}
+
+// NewMetrics creates the RPC collectors and registers them to reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	m := &Metrics{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_started_total",
+			Help: "Total number of RPCs started on the server.",
+		}, labels),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+		}, append(labels, "grpc_code")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Histogram of response latency (seconds) of RPCs handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_server_in_flight",
+			Help: "Number of RPCs currently being handled by the server.",
+		}, labels),
+		msgsRecv: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_received_total",
+			Help: "Total number of stream messages received by the server.",
+		}, labels),
+		msgsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_sent_total",
+			Help: "Total number of stream messages sent by the server.",
+		}, labels),
+	}
+	reg.MustRegister(m.started, m.handled, m.duration, m.inFlight, m.msgsRecv, m.msgsSent)
+	return m
+}
+
+// UnaryInterceptor returns the interceptor recording unary RPCs.
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		labels := rpcLabels("unary", info.FullMethod)
+		done := m.begin(labels)
+		rsp, err := handler(ctx, req)
+		done(err)
+		return rsp, err
+	}
+}
+
+// StreamInterceptor returns the interceptor recording stream RPCs and the
+// messages going through them.
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		labels := rpcLabels(streamType(info), info.FullMethod)
+		done := m.begin(labels)
+		err := handler(srv, &metricsStream{
+			ServerStream: ss,
+			recv:         m.msgsRecv.With(labels),
+			sent:         m.msgsSent.With(labels),
+		})
+		done(err)
+		return err
+	}
+}
+
+func (m *Metrics) begin(labels prometheus.Labels) func(error) {
+	start := time.Now()
+	m.started.With(labels).Inc()
+	inFlight := m.inFlight.With(labels)
+	inFlight.Inc()
+	return func(err error) {
+		inFlight.Dec()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+		handled := prometheus.Labels{"grpc_code": status.Code(err).String()}
+		for k, v := range labels {
+			handled[k] = v
+		}
+		m.handled.With(handled).Inc()
+	}
+}
+
+func rpcLabels(typ, fullMethod string) prometheus.Labels {
+	service, method := "unknown", "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		service, method = strings.TrimPrefix(fullMethod[:i], "/"), fullMethod[i+1:]
+	}
+	return prometheus.Labels{
+		"grpc_type":    typ,
+		"grpc_service": service,
+		"grpc_method":  method,
+	}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+// metricsStream counts the messages going through a stream.
+type metricsStream struct {
+	grpc.ServerStream
+	recv, sent prometheus.Counter
+}
+
+func (s *metricsStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recv.Inc()
+	}
+	return err
+}
+
+func (s *metricsStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Inc()
+	}
+	return err
+}
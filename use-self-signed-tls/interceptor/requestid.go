@@ -0,0 +1,116 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the metadata key carrying the request ID.
+const RequestIDKey = "x-request-id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID of the RPC, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// UnaryRequestID returns an interceptor that takes the request ID from the
+// incoming metadata, or assigns a new one, and makes it available through
+// RequestIDFromContext. The ID is sent back in the response header and
+// appended to the outgoing metadata so that it propagates to downstream
+// calls made with the handler context.
+//
+// RPCs failing before any response carry the ID in the trailer instead: a
+// header would prevent the trailers-only response that clients require to
+// retry the RPC.
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, id := withRequestID(ctx)
+		rsp, err := handler(ctx, req)
+		if err != nil {
+			grpc.SetTrailer(ctx, metadata.Pairs(RequestIDKey, id))
+		} else {
+			grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+		}
+		return rsp, err
+	}
+}
+
+// StreamRequestID is the stream counterpart of UnaryRequestID. The header is
+// set when the first message or header is sent.
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withRequestID(ss.Context())
+		rs := &requestIDStream{
+			wrappedStream: wrappedStream{ServerStream: ss, ctx: ctx},
+			md:            metadata.Pairs(RequestIDKey, id),
+		}
+		err := handler(srv, rs)
+		if !rs.headerSet {
+			ss.SetTrailer(rs.md)
+		}
+		return err
+	}
+}
+
+type requestIDStream struct {
+	wrappedStream
+	md        metadata.MD
+	headerSet bool
+}
+
+func (s *requestIDStream) setHeader() {
+	if !s.headerSet {
+		s.headerSet = true
+		s.ServerStream.SetHeader(s.md)
+	}
+}
+
+func (s *requestIDStream) SendHeader(md metadata.MD) error {
+	s.setHeader()
+	return s.ServerStream.SendHeader(md)
+}
+
+func (s *requestIDStream) SendMsg(m interface{}) error {
+	s.setHeader()
+	return s.ServerStream.SendMsg(m)
+}
+
+func withRequestID(ctx context.Context) (context.Context, string) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+	return ctx, id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// wrappedStream overrides the context of a grpc.ServerStream.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
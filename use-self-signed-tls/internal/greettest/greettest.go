@@ -0,0 +1,63 @@
+// Package greettest serves the GreetService handlers of greet_server in
+// process for tests.
+package greettest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetservice"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Limits are the GreetManyTimes limits of the test server.
+var Limits = greetservice.StreamLimits{MaxCount: 100, MaxInterval: time.Minute}
+
+// Server serves GreetService over an in-memory listener.
+type Server struct {
+	*grpc.Server
+	lis *bufconn.Listener
+}
+
+// NewServer serves the greet_server handlers with opts until the test ends.
+func NewServer(t testing.TB, opts ...grpc.ServerOption) *Server {
+	t.Helper()
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		lis:    bufconn.Listen(1 << 20),
+	}
+	greetpb.RegisterGreetServiceServer(s.Server, greetservice.NewServer(Limits))
+	go s.Serve(s.lis)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// Dial returns a client of s dialed in plaintext with opts, closed when the
+// test ends.
+func (s *Server) Dial(t testing.TB, opts ...grpc.DialOption) greetpb.GreetServiceClient {
+	t.Helper()
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	cc, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return greetpb.NewGreetServiceClient(cc)
+}
+
+// NewClient serves the greet_server handlers with opts and returns a client
+// connected to them.
+func NewClient(t testing.TB, opts ...grpc.ServerOption) greetpb.GreetServiceClient {
+	t.Helper()
+	return NewServer(t, opts...).Dial(t)
+}
@@ -0,0 +1,197 @@
+package lb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// FileScheme is the resolver scheme of targets naming a file listing their
+// backends.
+const FileScheme = "file"
+
+// DefaultFileInterval is how often the file of a file target is checked for
+// changes unless the target sets ?interval=.
+const DefaultFileInterval = time.Second
+
+func init() {
+	resolver.Register(fileBuilder{})
+}
+
+// Endpoints is the content of the file of a file target:
+//
+//	{
+//	  "addresses": [
+//	    {"addr": "localhost:50051", "weight": 3, "zone": "a"},
+//	    {"addr": "localhost:50052", "zone": "b", "server_name": "localhost"}
+//	  ],
+//	  "service_config": {"loadBalancingConfig": [{"weighted": {}}]}
+//	}
+//
+// The optional service config applies to the channel as if resolved by DNS.
+type Endpoints struct {
+	Addresses     []Endpoint      `json:"addresses"`
+	ServiceConfig json.RawMessage `json:"service_config,omitempty"`
+}
+
+// Endpoint is a backend address with its attributes.
+type Endpoint struct {
+	Addr string `json:"addr"`
+	// Weight is the weight of the address for the weighted balancer.
+	Weight uint32 `json:"weight,omitempty"`
+	// Zone is the zone the address is located in.
+	Zone string `json:"zone,omitempty"`
+	// ServerName overrides the name the TLS certificate of the address is
+	// verified with, which is otherwise the file path of the target.
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// ReadEndpoints reads and validates the endpoints in file.
+func ReadEndpoints(file string) (*Endpoints, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var e Endpoints
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, fmt.Errorf("parse %s:%v", file, err)
+	}
+	if len(e.Addresses) == 0 {
+		return nil, fmt.Errorf("%s: no addresses", file)
+	}
+	for i, a := range e.Addresses {
+		if a.Addr == "" {
+			return nil, fmt.Errorf("%s: addresses[%d]: addr is required", file, i)
+		}
+	}
+	return &e, nil
+}
+
+func (e *Endpoints) state(cc resolver.ClientConn) (resolver.State, error) {
+	var s resolver.State
+	for _, a := range e.Addresses {
+		addr := resolver.Address{Addr: a.Addr, ServerName: a.ServerName}
+		if a.Weight > 0 {
+			addr = SetWeight(addr, a.Weight)
+		}
+		if a.Zone != "" {
+			addr = SetZone(addr, a.Zone)
+		}
+		s.Addresses = append(s.Addresses, addr)
+	}
+	if len(e.ServiceConfig) > 0 {
+		s.ServiceConfig = cc.ParseServiceConfig(string(e.ServiceConfig))
+		if s.ServiceConfig.Err != nil {
+			return s, fmt.Errorf("service_config:%v", s.ServiceConfig.Err)
+		}
+	}
+	return s, nil
+}
+
+type fileBuilder struct{}
+
+func (fileBuilder) Scheme() string {
+	return FileScheme
+}
+
+// Build resolves targets such as file:///etc/greet/endpoints.json, or
+// file:///etc/greet/endpoints.json?interval=10s to check for changes every
+// 10s instead of every DefaultFileInterval.
+func (fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	interval := DefaultFileInterval
+	if v := target.URL.Query().Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("file target %q: invalid interval %q", target.URL.String(), v)
+		}
+		interval = d
+	}
+	r := &fileResolver{
+		file:     target.URL.Path,
+		cc:       cc,
+		resolve:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		interval: interval,
+	}
+	if err := r.update(); err != nil {
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// fileResolver pushes the endpoints of its file to the channel whenever the
+// file changes. A file that becomes unreadable or invalid is reported and
+// the last good endpoints are kept.
+type fileResolver struct {
+	file     string
+	cc       resolver.ClientConn
+	interval time.Duration
+	modTime  time.Time
+	resolve  chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (r *fileResolver) update() error {
+	fi, err := os.Stat(r.file)
+	if err != nil {
+		return err
+	}
+	// An invalid file is not read again until it changes.
+	r.modTime = fi.ModTime()
+	e, err := ReadEndpoints(r.file)
+	if err != nil {
+		return err
+	}
+	s, err := e.state(r.cc)
+	if err != nil {
+		return fmt.Errorf("%s:%v", r.file, err)
+	}
+	logger.Infof("resolved %s addresses:%d", r.file, len(s.Addresses))
+	return r.cc.UpdateState(s)
+}
+
+func (r *fileResolver) changed() bool {
+	fi, err := os.Stat(r.file)
+	return err == nil && !fi.ModTime().Equal(r.modTime)
+}
+
+func (r *fileResolver) watch() {
+	defer r.wg.Done()
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-t.C:
+			if !r.changed() {
+				continue
+			}
+		case <-r.resolve:
+		}
+		if err := r.update(); err != nil {
+			logger.Warningf("failed to resolve %s, keeping the last addresses:%v", r.file, err)
+		}
+	}
+}
+
+// ResolveNow rereads the file, as the channel asks when connections fail.
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolve <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
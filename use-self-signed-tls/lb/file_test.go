@@ -0,0 +1,268 @@
+package lb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// endpointsFile is an endpoints file rewritten by a test.
+type endpointsFile struct {
+	path    string
+	modTime time.Time
+}
+
+func newEndpointsFile(t *testing.T, e string) *endpointsFile {
+	t.Helper()
+	f := &endpointsFile{
+		path:    filepath.Join(t.TempDir(), "endpoints.json"),
+		modTime: time.Now().Add(-time.Hour),
+	}
+	f.write(t, e)
+	return f
+}
+
+// write replaces the content of the file with e and moves its modification
+// time forward, so that rewrites within the timestamp granularity are seen.
+func (f *endpointsFile) write(t *testing.T, e string) {
+	t.Helper()
+	if err := ioutil.WriteFile(f.path, []byte(e), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.modTime = f.modTime.Add(time.Second)
+	if err := os.Chtimes(f.path, f.modTime, f.modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *endpointsFile) target() string {
+	return "file://" + f.path + "?interval=10ms"
+}
+
+func endpoints(t *testing.T, addrs ...Endpoint) string {
+	t.Helper()
+	b, err := json.Marshal(Endpoints{Addresses: addrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFileResolverUpdates(t *testing.T) {
+	b := startBackends(t, "a", "b", "c")
+	f := newEndpointsFile(t, endpoints(t, Endpoint{Addr: "a"}, Endpoint{Addr: "b"}))
+	c := b.dial(t, f.target(), "round_robin")
+	waitReady(t, c, "a", "b")
+	checkSpread(t, greet(t, c, 300), map[string]int{"a": 1, "b": 1}, 0.02)
+
+	f.write(t, endpoints(t, Endpoint{Addr: "c"}))
+	waitOnly(t, c, "c")
+	checkSpread(t, greet(t, c, 100), map[string]int{"c": 1}, 0)
+
+	f.write(t, endpoints(t, Endpoint{Addr: "a"}, Endpoint{Addr: "c"}))
+	waitReady(t, c, "a", "c")
+	waitOnly(t, c, "a", "c")
+	checkSpread(t, greet(t, c, 300), map[string]int{"a": 1, "c": 1}, 0.02)
+}
+
+func TestFileResolverWeights(t *testing.T) {
+	b := startBackends(t, "a", "b")
+	f := newEndpointsFile(t, endpoints(t, Endpoint{Addr: "a"}, Endpoint{Addr: "b", Weight: 3}))
+	c := b.dial(t, f.target(), WeightedName)
+	waitReady(t, c, "a", "b")
+	checkSpread(t, greet(t, c, 400), map[string]int{"a": 1, "b": 3}, 0.02)
+
+	// Changing weights only keeps the connections and rebuilds the picker.
+	f.write(t, endpoints(t, Endpoint{Addr: "a", Weight: 4}, Endpoint{Addr: "b"}))
+	deadline := time.Now().Add(10 * time.Second)
+	for greet(t, c, 5)["a"] != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("weights were not updated")
+		}
+	}
+	checkSpread(t, greet(t, c, 500), map[string]int{"a": 4, "b": 1}, 0.02)
+}
+
+func TestFileResolverServiceConfig(t *testing.T) {
+	b := startBackends(t, "a", "b")
+	f := newEndpointsFile(t, `{
+  "addresses": [{"addr": "a"}, {"addr": "b", "weight": 3}],
+  "service_config": {"loadBalancingConfig": [{"weighted": {}}]}
+}`)
+	// The service config of the file overrides the default round_robin.
+	c := b.dial(t, f.target(), "round_robin")
+	waitReady(t, c, "a", "b")
+	checkSpread(t, greet(t, c, 400), map[string]int{"a": 1, "b": 3}, 0.02)
+}
+
+func TestFileResolverKeepsLastGoodAddresses(t *testing.T) {
+	b := startBackends(t, "a", "b")
+	f := newEndpointsFile(t, endpoints(t, Endpoint{Addr: "a"}))
+	c := b.dial(t, f.target(), "round_robin")
+	waitReady(t, c, "a")
+
+	for _, invalid := range []string{
+		`{"addresses": [{"addr": "b"}`,
+		`{"addresses": []}`,
+		`{"addresses": [{"addr": ""}]}`,
+		`{"addresses": [{"addr": "b"}], "service_config": {"loadBalancingConfig": [{"unknown": {}}]}}`,
+	} {
+		f.write(t, invalid)
+		// Leave the resolver several intervals to read the file.
+		time.Sleep(100 * time.Millisecond)
+		checkSpread(t, greet(t, c, 20), map[string]int{"a": 1}, 0)
+	}
+
+	f.write(t, endpoints(t, Endpoint{Addr: "b"}))
+	waitOnly(t, c, "b")
+}
+
+// waitOnly calls until 20 calls in a row are served by the backends in
+// names only.
+func waitOnly(t *testing.T, c greetpb.GreetServiceClient, names ...string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for n := 0; n < 20; {
+		if time.Now().After(deadline) {
+			t.Fatalf("calls still served by backends other than %v", names)
+		}
+		n++
+		for name := range greet(t, c, 1) {
+			if !contains(names, name) {
+				n = 0
+			}
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// recordingConn is a resolver.ClientConn recording the states it is sent.
+type recordingConn struct {
+	resolver.ClientConn
+
+	mu     sync.Mutex
+	states []resolver.State
+}
+
+func (c *recordingConn) UpdateState(s resolver.State) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.states = append(c.states, s)
+	return nil
+}
+
+func (c *recordingConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{}
+}
+
+// last waits until the resolver has sent n states and returns the last one.
+func (c *recordingConn) last(t *testing.T, n int) resolver.State {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		c.mu.Lock()
+		states := c.states
+		c.mu.Unlock()
+		if len(states) >= n {
+			return states[len(states)-1]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("resolver sent %d states, want %d", len(states), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *recordingConn) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.states)
+}
+
+func buildFileResolver(t *testing.T, target string, cc resolver.ClientConn) (resolver.Resolver, error) {
+	t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := resolver.Get(FileScheme).Build(resolver.Target{URL: *u}, cc, resolver.BuildOptions{})
+	if err == nil {
+		t.Cleanup(r.Close)
+	}
+	return r, err
+}
+
+func TestFileResolverAttributes(t *testing.T) {
+	f := newEndpointsFile(t, endpoints(t,
+		Endpoint{Addr: "a", Weight: 3, Zone: "zone-a"},
+		Endpoint{Addr: "b", Zone: "zone-b", ServerName: "greet.example.com"},
+	))
+	cc := &recordingConn{}
+	if _, err := buildFileResolver(t, f.target(), cc); err != nil {
+		t.Fatal(err)
+	}
+	type attrs struct {
+		addr, serverName, zone string
+		weight                 uint32
+	}
+	check := func(s resolver.State, want ...attrs) {
+		t.Helper()
+		if len(s.Addresses) != len(want) {
+			t.Fatalf("addresses = %v, want %d", s.Addresses, len(want))
+		}
+		for i, w := range want {
+			a := s.Addresses[i]
+			if got := (attrs{a.Addr, a.ServerName, Zone(a), Weight(a)}); got != w {
+				t.Errorf("address #%d = %+v, want %+v", i, got, w)
+			}
+		}
+	}
+	check(cc.last(t, 1),
+		attrs{addr: "a", zone: "zone-a", weight: 3},
+		attrs{addr: "b", serverName: "greet.example.com", zone: "zone-b", weight: 1},
+	)
+
+	f.write(t, endpoints(t, Endpoint{Addr: "b", Weight: 2, Zone: "zone-c"}))
+	check(cc.last(t, 2), attrs{addr: "b", zone: "zone-c", weight: 2})
+
+	f.write(t, `{"addresses": [{"addr": "c"}`)
+	time.Sleep(100 * time.Millisecond)
+	if n := cc.count(); n != 2 {
+		t.Errorf("resolver sent %d states after an invalid rewrite, want 2", n)
+	}
+}
+
+func TestFileResolverBuildErrors(t *testing.T) {
+	f := newEndpointsFile(t, endpoints(t, Endpoint{Addr: "a"}))
+	for _, target := range []string{
+		"file://" + f.path + "?interval=soon",
+		"file://" + f.path + "?interval=0s",
+		"file://" + f.path + "?interval=-1s",
+		"file://" + filepath.Join(filepath.Dir(f.path), "missing.json"),
+	} {
+		if _, err := buildFileResolver(t, target, &recordingConn{}); err == nil {
+			t.Errorf("Build(%q) succeeded, want error", target)
+		}
+	}
+	invalid := newEndpointsFile(t, `{"addresses": []}`)
+	if _, err := buildFileResolver(t, invalid.target(), &recordingConn{}); err == nil {
+		t.Errorf("Build(%q) succeeded, want error", invalid.target())
+	}
+}
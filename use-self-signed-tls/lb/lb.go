@@ -0,0 +1,53 @@
+// Package lb provides client-side load balancing across several backends:
+// the "static" resolver scheme listing the backends in the dial target, the
+// "file" resolver scheme reading them from a JSON file that is watched for
+// changes, and the "weighted" balancer spreading RPCs by per-address weights.
+//
+// Importing the package registers all of them, so that a client can dial
+//
+//	static:///localhost:50051,localhost:50052;weight=3
+//	file:///etc/greet/endpoints.json
+//
+// with a service config selecting the balancer, e.g.
+//
+//	{"loadBalancingConfig": [{"weighted": {}}]}
+//
+// Built-in alternatives are the dns:/// scheme and the round_robin balancer.
+package lb
+
+import (
+	"google.golang.org/grpc/resolver"
+)
+
+type (
+	weightKey struct{}
+	zoneKey   struct{}
+)
+
+// SetWeight returns addr carrying the weight w for the weighted balancer.
+// Weights are balancer attributes, so changing them keeps the connection.
+func SetWeight(addr resolver.Address, w uint32) resolver.Address {
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(weightKey{}, w)
+	return addr
+}
+
+// Weight returns the weight of addr, or 1 if it has none.
+func Weight(addr resolver.Address) uint32 {
+	w, ok := addr.BalancerAttributes.Value(weightKey{}).(uint32)
+	if !ok || w == 0 {
+		return 1
+	}
+	return w
+}
+
+// SetZone returns addr carrying the zone it is located in.
+func SetZone(addr resolver.Address, zone string) resolver.Address {
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(zoneKey{}, zone)
+	return addr
+}
+
+// Zone returns the zone of addr, or "" if unknown.
+func Zone(addr resolver.Address) string {
+	z, _ := addr.BalancerAttributes.Value(zoneKey{}).(string)
+	return z
+}
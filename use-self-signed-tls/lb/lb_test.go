@@ -0,0 +1,206 @@
+package lb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// backendServer answers Greet with the name of its backend.
+type backendServer struct {
+	greetpb.UnimplementedGreetServiceServer
+	name string
+}
+
+func (s *backendServer) Greet(context.Context, *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
+	return &greetpb.GreetResponse{Result: s.name}, nil
+}
+
+// backends are in-process servers addressed by name.
+type backends struct {
+	lis     map[string]*bufconn.Listener
+	servers map[string]*grpc.Server
+}
+
+func startBackends(t *testing.T, names ...string) *backends {
+	t.Helper()
+	b := &backends{
+		lis:     make(map[string]*bufconn.Listener),
+		servers: make(map[string]*grpc.Server),
+	}
+	for _, name := range names {
+		lis := bufconn.Listen(1 << 20)
+		s := grpc.NewServer()
+		greetpb.RegisterGreetServiceServer(s, &backendServer{name: name})
+		go s.Serve(lis)
+		t.Cleanup(s.Stop)
+		b.lis[name] = lis
+		b.servers[name] = s
+	}
+	return b
+}
+
+// dial connects to target, whose addresses are backend names, balancing
+// with policy.
+func (b *backends) dial(t *testing.T, target, policy string) greetpb.GreetServiceClient {
+	t.Helper()
+	cc, err := grpc.Dial(target,
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			lis, ok := b.lis[addr]
+			if !ok {
+				return nil, fmt.Errorf("unknown backend %q", addr)
+			}
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"`+policy+`": {}}]}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return greetpb.NewGreetServiceClient(cc)
+}
+
+// greet makes n calls and returns the calls served per backend.
+func greet(t *testing.T, c greetpb.GreetServiceClient, n int) map[string]int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		rsp, err := c.Greet(ctx, &greetpb.GreetRequest{}, grpc.WaitForReady(true))
+		if err != nil {
+			t.Fatalf("call #%d:%v", i, err)
+		}
+		counts[rsp.GetResult()]++
+	}
+	return counts
+}
+
+// waitReady calls until every backend in names has served a call, so that
+// the picker includes them all.
+func waitReady(t *testing.T, c greetpb.GreetServiceClient, names ...string) {
+	t.Helper()
+	seen := make(map[string]bool)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(seen) < len(names) {
+		if time.Now().After(deadline) {
+			t.Fatalf("backends %v not all ready, served by %v", names, seen)
+		}
+		for name := range greet(t, c, 1) {
+			seen[name] = true
+		}
+	}
+}
+
+// checkSpread checks that the calls in counts are spread over the backends
+// in proportion to weights, within tolerance of the total.
+func checkSpread(t *testing.T, counts map[string]int, weights map[string]int, tolerance float64) {
+	t.Helper()
+	total, totalWeight := 0, 0
+	for _, n := range counts {
+		total += n
+	}
+	for _, w := range weights {
+		totalWeight += w
+	}
+	for name, n := range counts {
+		if _, ok := weights[name]; !ok {
+			t.Errorf("backend %s served %d calls, want none", name, n)
+		}
+	}
+	for name, w := range weights {
+		want := float64(total) * float64(w) / float64(totalWeight)
+		if got := float64(counts[name]); got < want-tolerance*float64(total) || got > want+tolerance*float64(total) {
+			t.Errorf("backend %s served %d of %d calls, want %.0f (calls per backend %v)", name, counts[name], total, want, counts)
+		}
+	}
+}
+
+func TestWeightedSpread(t *testing.T) {
+	b := startBackends(t, "a", "b", "c")
+	c := b.dial(t, "static:///a,b;weight=2,c;weight=3", WeightedName)
+	waitReady(t, c, "a", "b", "c")
+	checkSpread(t, greet(t, c, 600), map[string]int{"a": 1, "b": 2, "c": 3}, 0.02)
+}
+
+func TestRoundRobinSpread(t *testing.T) {
+	b := startBackends(t, "a", "b", "c")
+	// round_robin ignores weights.
+	c := b.dial(t, "static:///a,b;weight=2,c;weight=3", "round_robin")
+	waitReady(t, c, "a", "b", "c")
+	checkSpread(t, greet(t, c, 600), map[string]int{"a": 1, "b": 1, "c": 1}, 0.02)
+}
+
+func TestFailover(t *testing.T) {
+	for _, policy := range []string{WeightedName, "round_robin"} {
+		t.Run(policy, func(t *testing.T) {
+			b := startBackends(t, "a", "b", "c")
+			c := b.dial(t, "static:///a,b,c", policy)
+			waitReady(t, c, "a", "b", "c")
+			checkSpread(t, greet(t, c, 300), map[string]int{"a": 1, "b": 1, "c": 1}, 0.02)
+
+			b.servers["b"].Stop()
+			// Calls in flight when b stops may still be picked for it
+			// until its connection is seen to be closed; none fail.
+			greet(t, c, 100)
+			checkSpread(t, greet(t, c, 300), map[string]int{"a": 1, "c": 1}, 0.02)
+		})
+	}
+}
+
+func TestParseStatic(t *testing.T) {
+	addrs, err := ParseStatic("localhost:50051, localhost:50052;weight=3,,unix:///tmp/greet.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		addr   string
+		weight uint32
+	}{
+		{"localhost:50051", 1},
+		{"localhost:50052", 3},
+		{"unix:///tmp/greet.sock", 1},
+	}
+	if len(addrs) != len(want) {
+		t.Fatalf("ParseStatic = %v, want %d addresses", addrs, len(want))
+	}
+	for i, w := range want {
+		if addrs[i].Addr != w.addr || Weight(addrs[i]) != w.weight {
+			t.Errorf("address #%d = %s weight %d, want %s weight %d", i, addrs[i].Addr, Weight(addrs[i]), w.addr, w.weight)
+		}
+	}
+
+	for _, endpoint := range []string{
+		"",
+		" , ",
+		"localhost:50051;weight=0",
+		"localhost:50051;weight=x",
+		"localhost:50051;zone=a",
+	} {
+		if _, err := ParseStatic(endpoint); err == nil {
+			t.Errorf("ParseStatic(%q) succeeded, want error", endpoint)
+		}
+	}
+}
+
+func TestWeightDefault(t *testing.T) {
+	if w := Weight(resolver.Address{Addr: "a"}); w != 1 {
+		t.Errorf("Weight without attribute = %d, want 1", w)
+	}
+	if w := Weight(SetWeight(resolver.Address{Addr: "a"}, 0)); w != 1 {
+		t.Errorf("Weight 0 = %d, want 1", w)
+	}
+	if z := Zone(SetZone(resolver.Address{Addr: "a"}, "zone-a")); z != "zone-a" {
+		t.Errorf("Zone = %q, want zone-a", z)
+	}
+}
@@ -0,0 +1,75 @@
+package lb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// StaticScheme is the resolver scheme of targets listing their backends.
+const StaticScheme = "static"
+
+func init() {
+	resolver.Register(staticBuilder{})
+}
+
+// staticBuilder resolves targets such as
+//
+//	static:///localhost:50051,localhost:50052;weight=3
+//
+// to the comma separated addresses of the endpoint, each optionally followed
+// by ";weight=N".
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string {
+	return StaticScheme
+}
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	addrs, err := ParseStatic(target.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		return nil, err
+	}
+	return staticResolver{}, nil
+}
+
+// ParseStatic parses the endpoint of a static target.
+func ParseStatic(endpoint string) ([]resolver.Address, error) {
+	var addrs []resolver.Address
+	for _, s := range strings.Split(endpoint, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		host, params, _ := strings.Cut(s, ";")
+		addr := resolver.Address{Addr: host}
+		if params != "" {
+			key, value, _ := strings.Cut(params, "=")
+			if key != "weight" {
+				return nil, fmt.Errorf("static target %q: unknown parameter %q", s, key)
+			}
+			w, err := strconv.ParseUint(value, 10, 32)
+			if err != nil || w == 0 {
+				return nil, fmt.Errorf("static target %q: invalid weight %q", s, value)
+			}
+			addr = SetWeight(addr, uint32(w))
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("static target %q: no addresses", endpoint)
+	}
+	return addrs, nil
+}
+
+// staticResolver has nothing to re-resolve.
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
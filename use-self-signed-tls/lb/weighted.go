@@ -0,0 +1,104 @@
+package lb
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/grpclog"
+)
+
+// WeightedName is the name of the weighted balancer.
+const WeightedName = "weighted"
+
+var logger = grpclog.Component("lb")
+
+func init() {
+	balancer.Register(weightedBuilder{})
+}
+
+// weightedBuilder builds balancers that connect to every address like
+// round_robin but pick them in proportion to their Weight, using smooth
+// weighted round robin.
+type weightedBuilder struct{}
+
+func (weightedBuilder) Name() string {
+	return WeightedName
+}
+
+func (weightedBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	b := &weightedBalancer{weights: make(map[string]uint32)}
+	b.Balancer = base.NewBalancerBuilder(WeightedName, b, base.Config{HealthCheck: true}).Build(cc, opts)
+	return b
+}
+
+// weightedBalancer is a base balancer keeping the weights of the latest
+// resolver update. The base balancer hands the picker builder the addresses
+// as they were when their SubConn was created, so weights updated later
+// would be lost.
+type weightedBalancer struct {
+	balancer.Balancer
+
+	mu      sync.Mutex
+	weights map[string]uint32
+}
+
+func (b *weightedBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	weights := make(map[string]uint32, len(s.ResolverState.Addresses))
+	for _, a := range s.ResolverState.Addresses {
+		weights[a.Addr] = Weight(a)
+	}
+	b.mu.Lock()
+	b.weights = weights
+	b.mu.Unlock()
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+func (b *weightedBalancer) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &weightedPicker{}
+	for sc, sci := range info.ReadySCs {
+		w, ok := b.weights[sci.Address.Addr]
+		if !ok {
+			w = Weight(sci.Address)
+		}
+		p.backends = append(p.backends, &weightedBackend{sc: sc, weight: int64(w)})
+		p.total += int64(w)
+	}
+	logger.Infof("weighted picker built with %d ready backends", len(p.backends))
+	return p
+}
+
+type weightedBackend struct {
+	sc      balancer.SubConn
+	weight  int64
+	current int64
+}
+
+// weightedPicker picks backends by smooth weighted round robin: each pick
+// adds every backend's weight to its current value and picks the backend
+// with the largest, which is then lowered by the total weight. Picks are
+// spread evenly rather than in bursts per backend.
+type weightedPicker struct {
+	mu       sync.Mutex
+	backends []*weightedBackend
+	total    int64
+}
+
+func (p *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *weightedBackend
+	for _, b := range p.backends {
+		b.current += b.weight
+		if best == nil || b.current > best.current {
+			best = b
+		}
+	}
+	best.current -= p.total
+	return balancer.PickResult{SubConn: best.sc}, nil
+}
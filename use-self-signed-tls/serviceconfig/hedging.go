@@ -0,0 +1,142 @@
+package serviceconfig
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// previousAttemptsKey is the metadata key telling the server how many copies
+// of a hedged RPC were sent before this one.
+const previousAttemptsKey = "grpc-previous-rpc-attempts"
+
+// UnaryHedgingInterceptor returns the client interceptor applying the
+// hedging policies of c to unary RPCs. Losing attempts are canceled once one
+// attempt succeeds or fails fatally.
+func (c *Config) UnaryHedgingInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := c.HedgingPolicy(method)
+		if _, ok := reply.(proto.Message); p == nil || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return p.invoke(ctx, method, req, reply.(proto.Message), cc, invoker, opts)
+	}
+}
+
+// attempt is one copy of a hedged RPC. The header, trailer and peer call
+// options are given per attempt and copied from the winning one.
+type attempt struct {
+	n       int
+	reply   proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+	err     error
+}
+
+func (p *HedgingPolicy) invoke(ctx context.Context, method string, req interface{}, reply proto.Message, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var header, trailer *metadata.MD
+	var pr *peer.Peer
+	var rest []grpc.CallOption
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			header = o.HeaderAddr
+		case grpc.TrailerCallOption:
+			trailer = o.TrailerAddr
+		case grpc.PeerCallOption:
+			pr = o.PeerAddr
+		default:
+			rest = append(rest, o)
+		}
+	}
+
+	done := make(chan *attempt, p.MaxAttempts)
+	started := 0
+	start := func() {
+		a := &attempt{
+			n:     started,
+			reply: reflect.New(reflect.TypeOf(reply).Elem()).Interface().(proto.Message),
+		}
+		actx := ctx
+		if a.n > 0 {
+			actx = metadata.AppendToOutgoingContext(ctx, previousAttemptsKey, strconv.Itoa(a.n))
+		}
+		aopts := append([]grpc.CallOption{grpc.Header(&a.header), grpc.Trailer(&a.trailer), grpc.Peer(&a.peer)}, rest...)
+		started++
+		go func() {
+			a.err = invoker(actx, method, req, a.reply, cc, aopts...)
+			done <- a
+		}()
+	}
+
+	start()
+	pending := 1
+	timer := time.NewTimer(p.HedgingDelay)
+	defer timer.Stop()
+	var last *attempt
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if started < p.MaxAttempts {
+				start()
+				pending++
+				timer.Reset(p.HedgingDelay)
+			}
+			continue
+		case last = <-done:
+			pending--
+		}
+		if last.err != nil && p.nonFatal(status.Code(last.err)) {
+			if started < p.MaxAttempts {
+				start()
+				pending++
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(p.HedgingDelay)
+			}
+			continue
+		}
+		break
+	}
+
+	if header != nil {
+		*header = last.header
+	}
+	if trailer != nil {
+		*trailer = last.trailer
+	}
+	if pr != nil {
+		*pr = last.peer
+	}
+	if last.err != nil {
+		return last.err
+	}
+	reply.Reset()
+	proto.Merge(reply, last.reply)
+	return nil
+}
+
+func (p *HedgingPolicy) nonFatal(code codes.Code) bool {
+	for _, c := range p.NonFatalStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
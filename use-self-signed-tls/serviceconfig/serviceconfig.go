@@ -0,0 +1,193 @@
+// Package serviceconfig configures gRPC clients with a service config
+// (https://github.com/grpc/grpc/blob/master/doc/service_config.md), such as
+//
+//	{
+//	  "methodConfig": [{
+//	    "name": [{"service": "greet.GreetService", "method": "Greet"}],
+//	    "timeout": "2s",
+//	    "waitForReady": true,
+//	    "retryPolicy": {
+//	      "maxAttempts": 4,
+//	      "initialBackoff": "0.1s",
+//	      "maxBackoff": "1s",
+//	      "backoffMultiplier": 2,
+//	      "retryableStatusCodes": ["UNAVAILABLE"]
+//	    }
+//	  }]
+//	}
+//
+// Timeouts, waitForReady, retry policies and retry throttling are applied by
+// grpc-go itself. Hedging policies, which grpc-go parses but ignores, are
+// applied to unary RPCs by an interceptor of this package.
+package serviceconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Config is a parsed service config.
+type Config struct {
+	raw string
+	// hedging maps method paths to their hedging policy. Paths are
+	// "/service/method", "/service/" for all methods of a service and ""
+	// for all methods, as in grpc-go.
+	hedging map[string]*HedgingPolicy
+}
+
+// HedgingPolicy sends up to MaxAttempts copies of an RPC, HedgingDelay apart,
+// and takes the first response. A failure with one of NonFatalStatusCodes
+// sends the next copy at once; any other failure ends the RPC.
+type HedgingPolicy struct {
+	MaxAttempts         int
+	HedgingDelay        time.Duration
+	NonFatalStatusCodes []codes.Code
+}
+
+// maxAttempts caps the attempts of retry and hedging policies like grpc-go
+// does.
+const maxAttempts = 5
+
+type jsonConfig struct {
+	MethodConfig []jsonMethodConfig `json:"methodConfig"`
+}
+
+type jsonMethodConfig struct {
+	Name []struct {
+		Service string `json:"service"`
+		Method  string `json:"method"`
+	} `json:"name"`
+	RetryPolicy   json.RawMessage `json:"retryPolicy"`
+	HedgingPolicy *struct {
+		MaxAttempts         int          `json:"maxAttempts"`
+		HedgingDelay        string       `json:"hedgingDelay"`
+		NonFatalStatusCodes []codes.Code `json:"nonFatalStatusCodes"`
+	} `json:"hedgingPolicy"`
+}
+
+// Parse parses the service config s. Settings handled by grpc-go are
+// validated when dialing.
+func Parse(s string) (*Config, error) {
+	var jc jsonConfig
+	if err := json.Unmarshal([]byte(s), &jc); err != nil {
+		return nil, fmt.Errorf("parse service config:%v", err)
+	}
+	c := &Config{raw: s, hedging: make(map[string]*HedgingPolicy)}
+	for i, mc := range jc.MethodConfig {
+		if mc.HedgingPolicy == nil {
+			continue
+		}
+		if mc.RetryPolicy != nil {
+			return nil, fmt.Errorf("methodConfig[%d]: retryPolicy and hedgingPolicy are mutually exclusive", i)
+		}
+		hp := mc.HedgingPolicy
+		if hp.MaxAttempts < 2 {
+			return nil, fmt.Errorf("methodConfig[%d]: hedgingPolicy.maxAttempts must be at least 2", i)
+		}
+		p := &HedgingPolicy{
+			MaxAttempts:         hp.MaxAttempts,
+			NonFatalStatusCodes: hp.NonFatalStatusCodes,
+		}
+		if p.MaxAttempts > maxAttempts {
+			p.MaxAttempts = maxAttempts
+		}
+		if hp.HedgingDelay != "" {
+			d, err := parseDuration(hp.HedgingDelay)
+			if err != nil {
+				return nil, fmt.Errorf("methodConfig[%d]: hedgingPolicy.hedgingDelay:%v", i, err)
+			}
+			p.HedgingDelay = d
+		}
+		for _, n := range mc.Name {
+			path := ""
+			if n.Service != "" {
+				path = "/" + n.Service + "/" + n.Method
+			} else if n.Method != "" {
+				return nil, fmt.Errorf("methodConfig[%d]: method %q without service", i, n.Method)
+			}
+			if _, ok := c.hedging[path]; ok {
+				return nil, fmt.Errorf("methodConfig[%d]: duplicate name %q", i, path)
+			}
+			c.hedging[path] = p
+		}
+	}
+	return c, nil
+}
+
+// ReadFile parses the service config in file.
+func ReadFile(file string) (*Config, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", file, err)
+	}
+	return c, nil
+}
+
+// parseDuration parses a google.protobuf.Duration in its JSON form, e.g.
+// "0.5s".
+func parseDuration(s string) (time.Duration, error) {
+	if !strings.HasSuffix(s, "s") {
+		return 0, errors.New("duration must end with s")
+	}
+	return time.ParseDuration(s)
+}
+
+// SetLoadBalancingPolicy makes c select the load balancing policy name,
+// such as round_robin, in place of any policy c selects.
+func (c *Config) SetLoadBalancingPolicy(name string) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(c.raw), &m); err != nil {
+		return err
+	}
+	if m == nil {
+		m = make(map[string]json.RawMessage)
+	}
+	delete(m, "loadBalancingPolicy")
+	lbc, err := json.Marshal([]map[string]struct{}{{name: {}}})
+	if err != nil {
+		return err
+	}
+	m["loadBalancingConfig"] = lbc
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.raw = string(b)
+	return nil
+}
+
+// DialOptions returns the dial options applying c to a client connection.
+// The service config resolved for the target, if any, takes precedence over
+// c for the settings handled by grpc-go.
+func (c *Config) DialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithDefaultServiceConfig(c.raw)}
+	if len(c.hedging) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(c.UnaryHedgingInterceptor()))
+	}
+	return opts
+}
+
+// HedgingPolicy returns the hedging policy of method, the full method name
+// "/service/method", or nil.
+func (c *Config) HedgingPolicy(method string) *HedgingPolicy {
+	if p, ok := c.hedging[method]; ok {
+		return p
+	}
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		if p, ok := c.hedging[method[:i+1]]; ok {
+			return p
+		}
+	}
+	return c.hedging[""]
+}
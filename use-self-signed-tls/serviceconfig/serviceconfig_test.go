@@ -0,0 +1,300 @@
+package serviceconfig
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	greetMethod     = "/greet.GreetService/Greet"
+	longGreetMethod = "/greet.GreetService/LongGreet"
+)
+
+// attempts counts the attempts of RPCs reaching the server by method,
+// before faults are injected into them.
+type attempts struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (a *attempts) add(method string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.n[method]++
+}
+
+func (a *attempts) get(method string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.n[method]
+}
+
+func (a *attempts) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	a.add(info.FullMethod)
+	return handler(ctx, req)
+}
+
+func (a *attempts) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	a.add(info.FullMethod)
+	return handler(srv, ss)
+}
+
+// sequence returns a Faults.Rand cycling through vs, so that faults are
+// injected into a fixed pattern of attempts.
+func sequence(vs ...float64) func() float64 {
+	var mu sync.Mutex
+	i := 0
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		v := vs[i%len(vs)]
+		i++
+		return v
+	}
+}
+
+// Values of sequence making an attempt fail, or be delayed, and not.
+const (
+	hit  = 0
+	miss = 0.99
+)
+
+// newTestClient serves GreetService in process with f injecting faults and
+// returns a client applying c, if not nil, along with the attempts reaching
+// the server.
+func newTestClient(t *testing.T, f *interceptor.Faults, c *Config) (greetpb.GreetServiceClient, *attempts) {
+	t.Helper()
+	a := &attempts{n: make(map[string]int)}
+	s := greettest.NewServer(t,
+		grpc.ChainUnaryInterceptor(a.unary, f.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(a.stream, f.StreamInterceptor()),
+	)
+	var opts []grpc.DialOption
+	if c != nil {
+		opts = c.DialOptions()
+	}
+	return s.Dial(t, opts...), a
+}
+
+// injected reports whether err is a failure with code injected by
+// interceptor.Faults, rather than one of the client or transport.
+func injected(err error, code codes.Code) bool {
+	st := status.Convert(err)
+	return st.Code() == code && strings.HasPrefix(st.Message(), interceptor.FaultMessage+":")
+}
+
+func mustParse(t *testing.T, s string) *Config {
+	t.Helper()
+	c, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func greet(ctx context.Context, c greetpb.GreetServiceClient) error {
+	_, err := c.Greet(ctx, &greetpb.GreetRequest{Greeting: &greetpb.Greeting{FirstName: "John"}})
+	return err
+}
+
+func longGreet(ctx context.Context, c greetpb.GreetServiceClient) error {
+	stream, err := c.LongGreet(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := stream.Send(&greetpb.LongGreetRequest{Greeting: &greetpb.Greeting{FirstName: name}}); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+const retryConfig = `{
+  "methodConfig": [{
+    "name": [{"service": "greet.GreetService", "method": "Greet"}],
+    "retryPolicy": {
+      "maxAttempts": 4,
+      "initialBackoff": "0.01s",
+      "maxBackoff": "0.05s",
+      "backoffMultiplier": 2,
+      "retryableStatusCodes": ["UNAVAILABLE"]
+    }
+  }]
+}`
+
+func TestRetryPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// The first two attempts of every call fail.
+	newFaults := func() *interceptor.Faults {
+		return &interceptor.Faults{
+			ErrorRate: 0.5,
+			Code:      codes.Unavailable,
+			Rand:      sequence(hit, hit, miss),
+		}
+	}
+
+	t.Run("without service config", func(t *testing.T) {
+		c, a := newTestClient(t, newFaults(), nil)
+		if err := greet(ctx, c); !injected(err, codes.Unavailable) {
+			t.Fatalf("Greet = %v, want an injected Unavailable", err)
+		}
+		if got := a.get(greetMethod); got != 1 {
+			t.Errorf("Greet attempts = %d, want 1", got)
+		}
+	})
+
+	t.Run("with retry policy", func(t *testing.T) {
+		c, a := newTestClient(t, newFaults(), mustParse(t, retryConfig))
+		const calls = 5
+		for i := 0; i < calls; i++ {
+			if err := greet(ctx, c); err != nil {
+				t.Fatalf("Greet #%d:%v", i, err)
+			}
+		}
+		if got, want := a.get(greetMethod), 3*calls; got != want {
+			t.Errorf("Greet attempts = %d, want %d", got, want)
+		}
+	})
+}
+
+// TestStreamsNotRetried checks that the sample service config retries the
+// idempotent Greet only, not streams such as LongGreet.
+func TestStreamsNotRetried(t *testing.T) {
+	sc, err := ReadFile("../config/service_config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, a := newTestClient(t, &interceptor.Faults{
+		ErrorRate: 1,
+		Code:      codes.Unavailable,
+	}, sc)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := longGreet(ctx, c); !injected(err, codes.Unavailable) {
+		t.Fatalf("LongGreet = %v, want an injected Unavailable", err)
+	}
+	if got := a.get(longGreetMethod); got != 1 {
+		t.Errorf("LongGreet attempts = %d, want 1", got)
+	}
+	if err := greet(ctx, c); !injected(err, codes.Unavailable) {
+		t.Fatalf("Greet = %v, want an injected Unavailable", err)
+	}
+	if got := a.get(greetMethod); got != 4 {
+		t.Errorf("Greet attempts = %d, want the 4 of the retry policy", got)
+	}
+}
+
+func hedgingConfig(delay string) string {
+	return `{
+  "methodConfig": [{
+    "name": [{"service": "greet.GreetService", "method": "Greet"}],
+    "hedgingPolicy": {
+      "maxAttempts": 3,
+      "hedgingDelay": "` + delay + `",
+      "nonFatalStatusCodes": ["UNAVAILABLE"]
+    }
+  }]
+}`
+}
+
+func TestHedgingPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("slow attempt", func(t *testing.T) {
+		// The first attempt takes 5s; the one hedged after 50ms wins.
+		c, a := newTestClient(t, &interceptor.Faults{
+			DelayRate: 0.5,
+			Delay:     5 * time.Second,
+			Rand:      sequence(hit, miss),
+		}, mustParse(t, hedgingConfig("0.05s")))
+		start := time.Now()
+		if err := greet(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Greet took %v, want the hedged attempt to answer first", elapsed)
+		}
+		if got := a.get(greetMethod); got < 2 {
+			t.Errorf("Greet attempts = %d, want at least 2", got)
+		}
+	})
+
+	t.Run("non-fatal failure", func(t *testing.T) {
+		// The first attempt fails with UNAVAILABLE, which sends the next
+		// one at once rather than after the 5s hedging delay.
+		c, a := newTestClient(t, &interceptor.Faults{
+			ErrorRate: 0.5,
+			Code:      codes.Unavailable,
+			Rand:      sequence(hit, miss),
+		}, mustParse(t, hedgingConfig("5s")))
+		start := time.Now()
+		if err := greet(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Greet took %v, want the next attempt sent at once", elapsed)
+		}
+		if got := a.get(greetMethod); got != 2 {
+			t.Errorf("Greet attempts = %d, want 2", got)
+		}
+	})
+
+	t.Run("fatal failure", func(t *testing.T) {
+		c, a := newTestClient(t, &interceptor.Faults{
+			ErrorRate: 1,
+			Code:      codes.InvalidArgument,
+		}, mustParse(t, hedgingConfig("5s")))
+		if err := greet(ctx, c); !injected(err, codes.InvalidArgument) {
+			t.Fatalf("Greet = %v, want an injected InvalidArgument", err)
+		}
+		if got := a.get(greetMethod); got != 1 {
+			t.Errorf("Greet attempts = %d, want 1", got)
+		}
+	})
+
+	t.Run("all attempts fail", func(t *testing.T) {
+		c, a := newTestClient(t, &interceptor.Faults{
+			ErrorRate: 1,
+			Code:      codes.Unavailable,
+		}, mustParse(t, hedgingConfig("5s")))
+		if err := greet(ctx, c); !injected(err, codes.Unavailable) {
+			t.Fatalf("Greet = %v, want an injected Unavailable", err)
+		}
+		if got := a.get(greetMethod); got != 3 {
+			t.Errorf("Greet attempts = %d, want 3", got)
+		}
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, config string
+	}{
+		{"invalid JSON", `{`},
+		{"retry and hedging", `{"methodConfig": [{"name": [{}], "retryPolicy": {}, "hedgingPolicy": {"maxAttempts": 2}}]}`},
+		{"single attempt", `{"methodConfig": [{"name": [{}], "hedgingPolicy": {"maxAttempts": 1}}]}`},
+		{"delay without unit", `{"methodConfig": [{"name": [{}], "hedgingPolicy": {"maxAttempts": 2, "hedgingDelay": "1"}}]}`},
+		{"method without service", `{"methodConfig": [{"name": [{"method": "Greet"}], "hedgingPolicy": {"maxAttempts": 2}}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.config); err == nil {
+				t.Errorf("Parse(%s) succeeded, want error", tt.config)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+)
+
+// span is the part of a span written by the stdout exporter checked here.
+type span struct {
+	Name        string
+	SpanKind    int
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+	Events []struct {
+		Name       string
+		Attributes []struct {
+			Key   string
+			Value struct {
+				Value interface{}
+			}
+		}
+	}
+}
+
+// messages counts the message events of s by message.type.
+func (s span) messages() map[string]int {
+	n := make(map[string]int)
+	for _, e := range s.Events {
+		if e.Name != "message" {
+			continue
+		}
+		for _, a := range e.Attributes {
+			if a.Key == "message.type" {
+				n[a.Value.Value.(string)]++
+			}
+		}
+	}
+	return n
+}
+
+// Span kinds as numbered by trace.SpanKind.
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+)
+
+func TestStreamingSpans(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := SetupTracing(context.Background(), TracingConfig{
+		ServiceName: "greet-test",
+		Exporter:    TraceExporterStdout,
+		Writer:      &buf,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := greettest.NewServer(t, ServerStatsHandler())
+	c := s.Dial(t, ClientStatsHandler())
+	names := []string{"Alice", "Bob", "Carol"}
+	ctx := context.Background()
+
+	long, err := c.LongGreet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range names {
+		if err := long.Send(&greetpb.LongGreetRequest{Greeting: &greetpb.Greeting{FirstName: n}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := long.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	everyone, err := c.GreetEveryone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range names {
+		if err := everyone.Send(&greetpb.GreetEveryoneRequest{Greeting: &greetpb.Greeting{FirstName: n}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := everyone.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	everyone.CloseSend()
+	if _, err := everyone.Recv(); err != io.EOF {
+		t.Fatalf("GreetEveryone did not end:%v", err)
+	}
+
+	// The server span ends after the client received the status; stopping
+	// the server waits for it.
+	s.Stop()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]map[int]span)
+	for dec := json.NewDecoder(&buf); dec.More(); {
+		var sp span
+		if err := dec.Decode(&sp); err != nil {
+			t.Fatal(err)
+		}
+		if spans[sp.Name] == nil {
+			spans[sp.Name] = make(map[int]span)
+		}
+		spans[sp.Name][sp.SpanKind] = sp
+	}
+
+	for _, tc := range []struct {
+		name string
+		// sent and received are the message events of the client span;
+		// the server span has them the other way round.
+		sent, received int
+	}{
+		{"greet.GreetService/LongGreet", 3, 1},
+		{"greet.GreetService/GreetEveryone", 3, 3},
+	} {
+		client, ok := spans[tc.name][spanKindClient]
+		if !ok {
+			t.Errorf("no client span %q in %v", tc.name, spans)
+			continue
+		}
+		server, ok := spans[tc.name][spanKindServer]
+		if !ok {
+			t.Errorf("no server span %q in %v", tc.name, spans)
+			continue
+		}
+		if server.SpanContext.TraceID != client.SpanContext.TraceID {
+			t.Errorf("%s: server trace %s, want client trace %s", tc.name, server.SpanContext.TraceID, client.SpanContext.TraceID)
+		}
+		if server.Parent.SpanID != client.SpanContext.SpanID {
+			t.Errorf("%s: server span parent %s, want client span %s", tc.name, server.Parent.SpanID, client.SpanContext.SpanID)
+		}
+		if got := client.messages(); got["SENT"] != tc.sent || got["RECEIVED"] != tc.received {
+			t.Errorf("%s: client message events %v, want %d SENT and %d RECEIVED", tc.name, got, tc.sent, tc.received)
+		}
+		if got := server.messages(); got["SENT"] != tc.received || got["RECEIVED"] != tc.sent {
+			t.Errorf("%s: server message events %v, want %d SENT and %d RECEIVED", tc.name, got, tc.received, tc.sent)
+		}
+	}
+}
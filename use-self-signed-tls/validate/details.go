@@ -0,0 +1,36 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// FormatError renders err for humans. Status errors are shown with their
+// code and message followed by one line per detail, e.g.
+//
+//	InvalidArgument: invalid request
+//	  greeting.first_name: must not be empty
+func FormatError(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err.Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: %v", st.Code(), st.Message())
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				fmt.Fprintf(&b, "\n  %v: %v", v.GetField(), v.GetDescription())
+			}
+		case error:
+			fmt.Fprintf(&b, "\n  undecodable detail: %v", d)
+		default:
+			fmt.Fprintf(&b, "\n  %v", d)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryInterceptor returns the interceptor rejecting requests violating the
+// rules with codes.InvalidArgument before they reach the handler.
+func (rules Rules) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := rules.Check(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is the stream counterpart of UnaryInterceptor. Every
+// received message is checked; the first invalid one fails the RPC.
+func (rules Rules) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss, rules: rules})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	rules Rules
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.rules.Check(m)
+}
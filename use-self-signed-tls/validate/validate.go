@@ -0,0 +1,142 @@
+// Package validate checks the string fields of request messages against
+// declarative rules and reports violations as codes.InvalidArgument with
+// google.rpc.BadRequest details.
+//
+// Rules are keyed by the full name of the field they apply to, so one rule
+// set covers every message embedding the field, e.g. greet.Greeting in all
+// GreetService requests.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// StringRule constrains a string field.
+type StringRule struct {
+	// Required rejects empty and whitespace only values.
+	Required bool
+	// MaxLen is the maximum length in characters. Zero means no limit.
+	MaxLen int
+	// Pattern is the regular expression non-empty values must match. Nil
+	// allows any value.
+	Pattern *regexp.Regexp
+	// PatternDesc describes the values Pattern matches in violation
+	// messages.
+	PatternDesc string
+}
+
+// Rules maps full field names, e.g. "greet.Greeting.first_name", to their
+// rule.
+type Rules map[protoreflect.FullName]StringRule
+
+// Person names are at most NameMaxLen characters matching NamePattern: a
+// letter followed by letters, combining marks, spaces, hyphens, apostrophes
+// and periods, e.g. "José", "O'Brien" or "Anne-Marie". The same max_len and
+// pattern constrain greeter.v1.HelloRequest.name in
+// use-buf/proto/greeter/v1/greeting.proto, so that GreetService and
+// GreetingService accept the same names; change both together.
+const (
+	NameMaxLen  = 64
+	NamePattern = `^\p{L}[\p{L}\p{M} .'-]*$`
+)
+
+// NameRule is the rule of person names.
+var NameRule = StringRule{
+	MaxLen:      NameMaxLen,
+	Pattern:     regexp.MustCompile(NamePattern),
+	PatternDesc: "a letter followed by letters, marks, spaces, hyphens, apostrophes and periods",
+}
+
+// NameRules are the rules of the names in the greet requests. The greeter.v1
+// requests declare their constraints in the proto files instead.
+var NameRules = Rules{
+	"greet.Greeting.first_name": required(NameRule),
+	"greet.Greeting.last_name":  NameRule,
+}
+
+func required(r StringRule) StringRule {
+	r.Required = true
+	return r
+}
+
+// Check returns a codes.InvalidArgument error carrying a google.rpc.BadRequest
+// with a field violation per invalid field of m, or nil when m is valid.
+func (rules Rules) Check(m interface{}) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	rules.walk(proto.MessageReflect(pm), "", true, &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	st := status.New(codes.InvalidArgument, "invalid request")
+	st, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request")
+	}
+	return st.Err()
+}
+
+func (rules Rules) walk(m protoreflect.Message, prefix string, present bool, violations *[]*errdetails.BadRequest_FieldViolation) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+		switch {
+		case fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap():
+			rule, ok := rules[fd.FullName()]
+			if !ok {
+				continue
+			}
+			if desc := rule.check(m.Get(fd).String()); desc != "" {
+				*violations = append(*violations, &errdetails.BadRequest_FieldViolation{
+					Field:       path,
+					Description: desc,
+				})
+			}
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				rules.walk(list.Get(j).Message(), fmt.Sprintf("%s[%d].", path, j), true, violations)
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap():
+			// An unset message is walked one level deep so its required
+			// fields are reported without descending into recursive types.
+			has := m.Has(fd)
+			if !has && !present {
+				continue
+			}
+			rules.walk(m.Get(fd).Message(), path+".", has, violations)
+		}
+	}
+}
+
+func (r StringRule) check(v string) string {
+	if strings.TrimSpace(v) == "" {
+		if r.Required {
+			return "must not be empty"
+		}
+		return ""
+	}
+	if !utf8.ValidString(v) {
+		return "must be valid UTF-8"
+	}
+	if n := utf8.RuneCountInString(v); r.MaxLen > 0 && n > r.MaxLen {
+		return fmt.Sprintf("must be at most %d characters, got %d", r.MaxLen, n)
+	}
+	if r.Pattern != nil && !r.Pattern.MatchString(v) {
+		return fmt.Sprintf("must be %s, got %q", r.PatternDesc, v)
+	}
+	return ""
+}
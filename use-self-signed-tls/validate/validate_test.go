@@ -0,0 +1,214 @@
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hrfmmr/grpc-go-sandbox/greet/greetpb"
+	"github.com/hrfmmr/grpc-go-sandbox/internal/greettest"
+	"github.com/hrfmmr/grpc-go-sandbox/validate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func newTestClient(t *testing.T) greetpb.GreetServiceClient {
+	t.Helper()
+	return greettest.NewClient(t,
+		grpc.UnaryInterceptor(validate.NameRules.UnaryInterceptor()),
+		grpc.StreamInterceptor(validate.NameRules.StreamInterceptor()),
+	)
+}
+
+// violations returns the fields of the BadRequest details of err, after
+// checking that err is codes.InvalidArgument.
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+	var fields []string
+	for _, d := range st.Details() {
+		br, ok := d.(*errdetails.BadRequest)
+		if !ok {
+			t.Fatalf("unexpected detail %v", d)
+		}
+		for _, v := range br.GetFieldViolations() {
+			if v.GetDescription() == "" {
+				t.Errorf("violation of %s has no description", v.GetField())
+			}
+			fields = append(fields, v.GetField())
+		}
+	}
+	return fields
+}
+
+func TestGreet(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	long := strings.Repeat("a", validate.NameMaxLen+1)
+	for _, tt := range []struct {
+		name     string
+		greeting *greetpb.Greeting
+		want     []string
+	}{
+		{"valid", &greetpb.Greeting{FirstName: "José", LastName: "O'Brien"}, nil},
+		{"valid without last name", &greetpb.Greeting{FirstName: "Anne-Marie"}, nil},
+		{"max length", &greetpb.Greeting{FirstName: long[1:]}, nil},
+		{"no greeting", nil, []string{"greeting.first_name"}},
+		{"empty first name", &greetpb.Greeting{LastName: "Doe"}, []string{"greeting.first_name"}},
+		{"blank first name", &greetpb.Greeting{FirstName: "  "}, []string{"greeting.first_name"}},
+		{"over-long first name", &greetpb.Greeting{FirstName: long}, []string{"greeting.first_name"}},
+		{"over-long multibyte name", &greetpb.Greeting{FirstName: strings.Repeat("é", validate.NameMaxLen+1)}, []string{"greeting.first_name"}},
+		{"bad first name character", &greetpb.Greeting{FirstName: "<script>"}, []string{"greeting.first_name"}},
+		{"bad last name character", &greetpb.Greeting{FirstName: "John", LastName: "Doe3"}, []string{"greeting.last_name"}},
+		{"leading hyphen", &greetpb.Greeting{FirstName: "-John"}, []string{"greeting.first_name"}},
+		{"both names", &greetpb.Greeting{FirstName: long, LastName: "Doe_"}, []string{"greeting.first_name", "greeting.last_name"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.Greet(ctx, &greetpb.GreetRequest{Greeting: tt.greeting})
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := rsp.GetResult(), "Hello "+tt.greeting.GetFirstName(); got != want {
+					t.Errorf("Greet = %q, want %q", got, want)
+				}
+				return
+			}
+			if got := violations(t, err); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongGreet(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	send := func(names ...string) (*greetpb.LongGreetResponse, error) {
+		stream, err := c.LongGreet(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			err := stream.Send(&greetpb.LongGreetRequest{Greeting: &greetpb.Greeting{FirstName: name}})
+			if err == io.EOF {
+				// The server ended the RPC; CloseAndRecv returns why.
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return stream.CloseAndRecv()
+	}
+
+	rsp, err := send("Alice", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rsp.GetResult(), "Hello Alice! Hello Bob! "; got != want {
+		t.Errorf("LongGreet = %q, want %q", got, want)
+	}
+	_, err = send("Alice", "", "Carol")
+	if got := violations(t, err); strings.Join(got, ",") != "greeting.first_name" {
+		t.Errorf("violations = %v, want [greeting.first_name]", got)
+	}
+}
+
+func TestGreetEveryone(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := c.GreetEveryone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: &greetpb.Greeting{FirstName: "Alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: &greetpb.Greeting{FirstName: "Bob", LastName: "<b>"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if got := violations(t, err); strings.Join(got, ",") != "greeting.last_name" {
+		t.Errorf("violations = %v, want [greeting.last_name]", got)
+	}
+}
+
+// TestCheckLists checks the field paths of messages in lists and that unset
+// recursive messages are walked one level deep only.
+func TestCheckLists(t *testing.T) {
+	rules := validate.Rules{
+		"google.protobuf.DescriptorProto.name": {Required: true},
+	}
+	name := func(s string) *string { return &s }
+	err := rules.Check(&descriptorpb.FileDescriptorProto{
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: name("Greeting")},
+			{NestedType: []*descriptorpb.DescriptorProto{{Name: name("Inner")}, {}}},
+		},
+	})
+	want := "message_type[1].name,message_type[1].nested_type[1].name"
+	if got := violations(t, err); strings.Join(got, ",") != want {
+		t.Errorf("violations = %v, want %v", got, want)
+	}
+	if err := rules.Check(&descriptorpb.FileDescriptorProto{}); err != nil {
+		t.Errorf("Check of a message without lists = %v, want nil", err)
+	}
+	if err := rules.Check("not a message"); err != nil {
+		t.Errorf("Check of a non message = %v, want nil", err)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "greeting.first_name", Description: "must not be empty"},
+			{Field: "greeting.last_name", Description: "must be at most 64 characters, got 65"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"bad request", st.Err(), "InvalidArgument: invalid request\n" +
+			"  greeting.first_name: must not be empty\n" +
+			"  greeting.last_name: must be at most 64 characters, got 65"},
+		{"status without details", status.Error(codes.Unavailable, "connection refused"), "Unavailable: connection refused"},
+		{"not a status", errors.New("boom"), "boom"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validate.FormatError(tt.err); got != tt.want {
+				t.Errorf("FormatError = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Through a real server the details survive the transport.
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.Greet(ctx, &greetpb.GreetRequest{Greeting: &greetpb.Greeting{LastName: "Doe"}})
+	if got, want := validate.FormatError(err), "InvalidArgument: invalid request\n  greeting.first_name: must not be empty"; got != want {
+		t.Errorf("FormatError = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package web
+
+import (
+	"net/textproto"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/hrfmmr/grpc-go-sandbox/interceptor"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// NewGatewayMux returns the REST gateway mux to register the generated
+// HandlerFromEndpoint functions on. Besides the headers forwarded by default,
+// such as Authorization, it forwards the request ID header so logs on both
+// sides can be correlated. Error details such as google.rpc.BadRequest are
+// rendered in the JSON error body.
+func NewGatewayMux() *runtime.ServeMux {
+	return runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(headerMatcher))
+}
+
+func headerMatcher(key string) (string, bool) {
+	if textproto.CanonicalMIMEHeaderKey(key) == textproto.CanonicalMIMEHeaderKey(interceptor.RequestIDKey) {
+		return interceptor.RequestIDKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
@@ -0,0 +1,58 @@
+// Package web serves gRPC services to browsers over gRPC-Web.
+package web
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"google.golang.org/grpc"
+)
+
+// NewHandler returns the HTTP handler translating gRPC-Web requests,
+// including server streaming, to calls on s. Cross-origin requests are
+// allowed from allowedOrigins only; "*" allows any origin. Requests that are
+// not gRPC-Web are passed to next, or get 404 when next is nil.
+func NewHandler(s *grpc.Server, allowedOrigins []string, next http.Handler) http.Handler {
+	if next == nil {
+		next = http.NotFoundHandler()
+	}
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		origins[o] = true
+	}
+	wrapped := grpcweb.WrapServer(s,
+		grpcweb.WithOriginFunc(func(origin string) bool {
+			return origins["*"] || origins[origin]
+		}),
+		grpcweb.WithAllowedRequestHeaders([]string{"content-type", "authorization", "x-request-id", "x-grpc-web", "x-user-agent", "grpc-timeout"}),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wrapped.IsGrpcWebRequest(r) || wrapped.IsAcceptableGrpcCorsRequest(r) {
+			wrapped.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve serves h on addr in the background, over TLS when cfg is not nil.
+// The returned server should be closed on shutdown.
+func Serve(addr string, h http.Handler, cfg *tls.Config) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: h, TLSConfig: cfg}
+	go func() {
+		var err error
+		if cfg != nil {
+			log.Printf("Serving gRPC-Web on https://%v\n", addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Serving gRPC-Web on http://%v\n", addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Failed to serve gRPC-Web:%v\n", err)
+		}
+	}()
+	return srv
+}
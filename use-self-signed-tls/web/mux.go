@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// Multiplex returns the handler serving native gRPC requests with s and
+// every other request, such as gRPC-Web, REST or /metrics, with h, so that
+// one listener can serve them all.
+//
+// gRPC requests served this way go through grpc.Server.ServeHTTP, which
+// grpc.Server.GracefulStop cannot drain: wrap the handler with a Tracker and
+// drain it before stopping s.
+func Multiplex(s *grpc.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && isGRPC(r.Header.Get("Content-Type")) {
+			s.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func isGRPC(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc") &&
+		!strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+// Tracker tracks the requests in flight through the handlers it wraps, so
+// that shutdown can wait for the RPCs served through grpc.Server.ServeHTTP.
+// http.Server.Shutdown does not wait for requests on hijacked connections,
+// such as the h2c connections of ServeH2C.
+type Tracker struct {
+	mu       sync.Mutex
+	inflight sync.WaitGroup
+	draining bool
+}
+
+// Wrap returns h counting its requests in t. Once t is draining, new requests
+// are refused with 503 Service Unavailable, which gRPC clients see as
+// UNAVAILABLE.
+func (t *Tracker) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		if t.draining {
+			t.mu.Unlock()
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		t.inflight.Add(1)
+		t.mu.Unlock()
+		defer t.inflight.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Drain refuses new requests and waits for the requests in flight to finish.
+// It returns ctx.Err() if ctx is done first.
+func (t *Tracker) Drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		t.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ServeTLS serves h on lis in the background over TLS, negotiating HTTP/2 or
+// HTTP/1.1 with ALPN. The returned server should be shut down on exit.
+func ServeTLS(lis net.Listener, h http.Handler, cfg *tls.Config) *http.Server {
+	srv := &http.Server{Handler: h, TLSConfig: cfg}
+	go func() {
+		log.Printf("Serving gRPC and HTTP over TLS on %v\n", lis.Addr())
+		if err := srv.ServeTLS(lis, "", ""); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve:%v", err)
+		}
+	}()
+	return srv
+}
+
+// ServeH2C serves h on lis in the background in plaintext, accepting both
+// HTTP/2 without TLS (h2c), as used by gRPC clients, and HTTP/1.1. The
+// returned server should be shut down on exit. Shutting it down sends GOAWAY
+// on h2c connections but does not wait for them: when t is not nil, it tracks
+// the h2c connections so that draining t waits until they are closed, after
+// their last response has been written.
+func ServeH2C(lis net.Listener, h http.Handler, t *Tracker) *http.Server {
+	h2s := &http2.Server{}
+	srv := &http.Server{Handler: h2c.NewHandler(h, h2s)}
+	if t != nil {
+		srv.Handler = t.Wrap(srv.Handler)
+	}
+	// Registers h2s for the graceful shutdown of srv.
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		log.Fatalf("Failed to configure HTTP/2:%v", err)
+	}
+	go func() {
+		log.Printf("Serving gRPC and HTTP in plaintext on %v\n", lis.Addr())
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve:%v", err)
+		}
+	}()
+	return srv
+}